@@ -8,7 +8,7 @@ import SearchFiles from './SearchFiles'
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card"
 import { Button } from "@/components/ui/button"
 import { LogOut, Upload, Search, FileText, User } from 'lucide-react'
-import { fetchUserInfo } from '@/lib/api'
+import { fetchUserInfo, logout } from '@/lib/api'
 
 export default function DashboardContent() {
   const router = useRouter()
@@ -40,8 +40,8 @@ export default function DashboardContent() {
     getUserInfo()
   }, [refreshTrigger])
 
-  const handleLogout = () => {
-    localStorage.removeItem('token')
+  const handleLogout = async () => {
+    await logout()
     router.push('/login')
   }
 
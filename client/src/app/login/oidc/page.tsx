@@ -0,0 +1,109 @@
+// src/app/login/oidc/page.tsx
+'use client'
+
+import Link from 'next/link'
+import { useEffect, useState } from 'react'
+import { useRouter } from 'next/navigation'
+import { Button } from "@/components/ui/button"
+import { Input } from "@/components/ui/input"
+import { Label } from "@/components/ui/label"
+import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert"
+import { Loader2, Link2 } from 'lucide-react'
+import { saveTokens } from '@/lib/api'
+
+const API_URL = process.env.API_URL || 'http://localhost:8080';
+
+// The server sends the browser here after an SSO login, with the tokens or
+// the error in the URL fragment. When the email belongs to an account with a
+// password, the fragment holds a link token and the password links the two.
+export default function OIDCCallbackPage() {
+  const [error, setError] = useState('')
+  const [linkToken, setLinkToken] = useState('')
+  const [password, setPassword] = useState('')
+  const [isLoading, setIsLoading] = useState(false)
+  const router = useRouter()
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1))
+    // Keep the tokens out of the history
+    window.history.replaceState(null, '', window.location.pathname)
+
+    const accessToken = params.get('access_token')
+    if (accessToken) {
+      saveTokens({ token: accessToken, refresh_token: params.get('refresh_token') || undefined })
+      router.replace('/dashboard')
+    } else {
+      setLinkToken(params.get('link_token') || '')
+      setError(params.get('error') || 'Login failed')
+    }
+  }, [router])
+
+  const handleLink = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setIsLoading(true)
+
+    try {
+      const response = await fetch(`${API_URL}/auth/oidc/link`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ link_token: linkToken, password }),
+      })
+      const data = await response.json()
+      if (response.ok) {
+        saveTokens(data)
+        router.replace('/dashboard')
+        return
+      }
+      // A link token works once, so any failure means starting over
+      setLinkToken('')
+      setError(data.error || 'Linking failed')
+    } catch (_) {
+      setLinkToken('')
+      setError('An error occurred. Please try again.')
+    } finally {
+      setIsLoading(false)
+    }
+  }
+
+  return (
+    <div className="flex justify-center items-center min-h-screen bg-[#1c1c1c] text-white p-4">
+      {error ? (
+        <div className="w-full max-w-md space-y-6">
+          <Alert variant="destructive" className="bg-red-500/20 border-red-500/50">
+            <AlertTitle className="text-white font-semibold">Error</AlertTitle>
+            <AlertDescription className="text-white/90">{error}</AlertDescription>
+          </Alert>
+          {linkToken && (
+            <form onSubmit={handleLink} className="space-y-4">
+              <div className="flex flex-col space-y-2">
+                <Label htmlFor="password" className="text-white text-lg">Password</Label>
+                <Input
+                  id="password"
+                  type="password"
+                  placeholder="Enter your password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  disabled={isLoading}
+                  className="bg-[#1e1e1e] text-white placeholder-gray-500 border-[#2a2a2a] focus:border-[#22c55e] transition-all duration-300"
+                />
+              </div>
+              <Button type="submit" disabled={isLoading} size="lg" className="w-full bg-[#22c55e] hover:bg-[#1ea34b] text-white transition-colors duration-300">
+                {isLoading ? (
+                  <Loader2 className="mr-2 h-5 w-5 animate-spin" />
+                ) : (
+                  <Link2 className="mr-2 h-5 w-5" />
+                )}
+                Link account
+              </Button>
+            </form>
+          )}
+          <Button asChild size="lg" className="w-full bg-[#22c55e] hover:bg-[#1ea34b] text-white transition-colors duration-300">
+            <Link href="/login">Back to login</Link>
+          </Button>
+        </div>
+      ) : (
+        <Loader2 className="h-10 w-10 animate-spin text-[#22c55e]" />
+      )}
+    </div>
+  )
+}
@@ -9,7 +9,8 @@ import { Input } from "@/components/ui/input"
 import { Label } from "@/components/ui/label"
 import { Card, CardContent, CardDescription, CardFooter, CardHeader, CardTitle } from "@/components/ui/card"
 import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert"
-import { LogIn, UserPlus, Loader2 } from 'lucide-react'
+import { LogIn, UserPlus, Loader2, KeyRound } from 'lucide-react'
+import { saveTokens } from '@/lib/api'
 
 const API_URL = process.env.API_URL || 'http://localhost:8080';
 
@@ -34,7 +35,7 @@ export default function LoginPage() {
 
       if (response.ok) {
         const data = await response.json()
-        localStorage.setItem('token', data.token)
+        saveTokens(data)
         router.push('/dashboard')
       } else {
         const data = await response.json()
@@ -114,6 +115,11 @@ export default function LoginPage() {
               </>
             )}
           </Button>
+          <Button asChild size="lg" variant="outline" className="w-full border-[#2a2a2a] text-white hover:bg-[#2a2a2a] transition-colors duration-300">
+            <a href={`${API_URL}/auth/oidc/login`} className="flex items-center justify-center">
+              <KeyRound className="mr-2 h-5 w-5" /> Sign in with SSO
+            </a>
+          </Button>
           <Button asChild size="lg" variant="outline" className="w-full border-[#22c55e] text-[#22c55e] hover:bg-[#22c55e] hover:text-white transition-colors duration-300">
             <Link href="/register" className="flex items-center justify-center">
               <UserPlus className="mr-2 h-5 w-5" /> Register
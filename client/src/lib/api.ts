@@ -2,14 +2,45 @@
 
 const API_URL = process.env.API_URL || 'http://localhost:8080';
 
+export function saveTokens(data: { token: string; refresh_token?: string }) {
+  localStorage.setItem('token', data.token);
+  if (data.refresh_token) {
+    localStorage.setItem('refresh_token', data.refresh_token);
+  }
+}
+
+// Access tokens are short lived; swap the refresh token for a new pair.
+async function refreshTokens() {
+  const refreshToken = localStorage.getItem('refresh_token');
+  if (!refreshToken) return false;
+
+  const response = await fetch(`${API_URL}/refresh`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  });
+  if (!response.ok) {
+    localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
+    return false;
+  }
+  saveTokens(await response.json());
+  return true;
+}
+
 async function fetchWithAuth(endpoint: string, options: RequestInit = {}) {
-  const token = localStorage.getItem('token');
-  const headers = {
-    ...options.headers,
-    'Authorization': `Bearer ${token}`,
-  };
+  const send = () => fetch(`${API_URL}${endpoint}`, {
+    ...options,
+    headers: {
+      ...options.headers,
+      'Authorization': `Bearer ${localStorage.getItem('token')}`,
+    },
+  });
 
-  const response = await fetch(`${API_URL}${endpoint}`, { ...options, headers });
+  let response = await send();
+  if (response.status === 401 && await refreshTokens()) {
+    response = await send();
+  }
   if (!response.ok) {
     throw new Error('API request failed');
   }
@@ -81,4 +112,14 @@ export async function fetchUserInfo() {
     console.error('Error fetching user data:', error);
     throw error;
   }
-}
\ No newline at end of file
+}
+
+export async function logout() {
+  try {
+    await fetchWithAuth('/logout', { method: 'POST' });
+  } catch (_) {
+    // The tokens are dropped either way
+  }
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+}
@@ -0,0 +1,139 @@
+// internal/authz/authz.go
+package authz
+
+import (
+	"fmt"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ActionView   = "view"
+	ActionEdit   = "edit"
+	ActionDelete = "delete"
+	ActionShare  = "share"
+)
+
+// maxFolderDepth stops the walk up the folder tree on corrupt data.
+const maxFolderDepth = 100
+
+// Can reports whether the user may perform the action on a resource, which
+// must be a *models.FileMetadata or a *models.Folder.
+func Can(db *gorm.DB, user models.User, action string, resource interface{}) (bool, error) {
+	var role string
+	var err error
+	switch r := resource.(type) {
+	case *models.FileMetadata:
+		role, err = FileRole(db, user.ID, r)
+	case *models.Folder:
+		role, err = FolderRole(db, user.ID, r)
+	default:
+		return false, fmt.Errorf("unsupported resource type %T", resource)
+	}
+	if err != nil {
+		return false, err
+	}
+	return RoleAllows(role, action), nil
+}
+
+// RoleAllows reports whether a role may perform an action. Viewers can read,
+// editors can also change and delete, and only owners can share.
+func RoleAllows(role, action string) bool {
+	switch action {
+	case ActionView:
+		return models.RoleRank(role) >= models.RoleRank(models.RoleViewer)
+	case ActionEdit, ActionDelete:
+		return models.RoleRank(role) >= models.RoleRank(models.RoleEditor)
+	case ActionShare:
+		return role == models.RoleOwner
+	}
+	return false
+}
+
+// FileRole returns the user's role on a file: owner for the file's own user,
+// otherwise the highest role granted on the file or any folder above it.
+// An empty role means the user has no access.
+func FileRole(db *gorm.DB, userID uuid.UUID, file *models.FileMetadata) (string, error) {
+	if file.UserID == userID {
+		return models.RoleOwner, nil
+	}
+
+	folderIDs, err := ancestorFolderIDs(db, file.FolderID)
+	if err != nil {
+		return "", err
+	}
+	return grantedRole(db, userID, []uuid.UUID{file.ID}, folderIDs)
+}
+
+// FolderRole returns the user's role on a folder, inherited from the folders
+// above it like FileRole.
+func FolderRole(db *gorm.DB, userID uuid.UUID, folder *models.Folder) (string, error) {
+	if folder.UserID == userID {
+		return models.RoleOwner, nil
+	}
+
+	folderIDs, err := ancestorFolderIDs(db, &folder.ID)
+	if err != nil {
+		return "", err
+	}
+	return grantedRole(db, userID, nil, folderIDs)
+}
+
+// InFolder reports whether folderID is root or a folder somewhere inside
+// it. A nil folderID is the top level, which is inside no folder.
+func InFolder(db *gorm.DB, folderID *uuid.UUID, root uuid.UUID) (bool, error) {
+	folderIDs, err := ancestorFolderIDs(db, folderID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range folderIDs {
+		if id == root {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ancestorFolderIDs returns the folder and every folder above it.
+func ancestorFolderIDs(db *gorm.DB, folderID *uuid.UUID) ([]uuid.UUID, error) {
+	var folderIDs []uuid.UUID
+	for folderID != nil && len(folderIDs) < maxFolderDepth {
+		var folder models.Folder
+		if result := db.Select("id", "parent_id").First(&folder, "id = ?", *folderID); result.Error != nil {
+			return nil, result.Error
+		}
+		folderIDs = append(folderIDs, folder.ID)
+		folderID = folder.ParentID
+	}
+	return folderIDs, nil
+}
+
+func grantedRole(db *gorm.DB, userID uuid.UUID, fileIDs []uuid.UUID, folderIDs []uuid.UUID) (string, error) {
+	query := db.Where("user_id = ?", userID)
+	switch {
+	case len(fileIDs) > 0 && len(folderIDs) > 0:
+		query = query.Where("(resource_type = ? AND resource_id IN ?) OR (resource_type = ? AND resource_id IN ?)",
+			models.ResourceFile, fileIDs, models.ResourceFolder, folderIDs)
+	case len(fileIDs) > 0:
+		query = query.Where("resource_type = ? AND resource_id IN ?", models.ResourceFile, fileIDs)
+	case len(folderIDs) > 0:
+		query = query.Where("resource_type = ? AND resource_id IN ?", models.ResourceFolder, folderIDs)
+	default:
+		return "", nil
+	}
+
+	var permissions []models.Permission
+	if result := query.Find(&permissions); result.Error != nil {
+		return "", result.Error
+	}
+
+	role := ""
+	for _, permission := range permissions {
+		if models.RoleRank(permission.Role) > models.RoleRank(role) {
+			role = permission.Role
+		}
+	}
+	return role, nil
+}
@@ -0,0 +1,220 @@
+// internal/blobs/blobs.go
+package blobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// Long enough for a server side copy of a large object
+	lockTTL     = 10 * time.Minute
+	lockWait    = 30 * time.Second
+	lockBackoff = 50 * time.Millisecond
+)
+
+const KindDeleteBlob = "delete_blob"
+
+var errBlobNotFound = errors.New("blob not found")
+
+// Manager keeps the reference counts of blobs and removes their objects
+// once nothing refers to them.
+type Manager struct {
+	db      *gorm.DB
+	cache   *redis.Client
+	storage storage.Backend
+	outbox  *outbox.Outbox
+}
+
+// New returns a Manager and registers its outbox event handler.
+func New(db *gorm.DB, cache *redis.Client, store storage.Backend, box *outbox.Outbox) *Manager {
+	m := &Manager{db: db, cache: cache, storage: store, outbox: box}
+	box.Register(KindDeleteBlob, m.deleteBlob)
+	return m
+}
+
+// ObjectName returns the content-addressed key of the blob with the given
+// SHA-256 hex digest.
+func ObjectName(hash string) string {
+	return "blobs/" + hash[:2] + "/" + hash
+}
+
+// Store takes an uploaded object and adds a reference to the blob with its
+// content. The first reference moves the content to the blob's key; later
+// ones only count up. The uploaded object is removed through the outbox
+// either way. save records whatever holds the reference, given the blob's
+// key, in the same transaction, so a reference is never counted without
+// its holder.
+func (m *Manager) Store(ctx context.Context, uploadedObject string, hash string, size int64, save func(tx *gorm.DB, objectName string) error) (string, error) {
+	objectName := ObjectName(hash)
+
+	unlock, err := m.lock(ctx, hash)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// The count is changed in SQL so it can't race with ReleaseTx, which
+	// runs in other transactions without the lock
+	created := false
+	err = m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Blob{}).Where("hash = ?", hash).UpdateColumn("ref_count", gorm.Expr("ref_count + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("error updating blob: %v", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			_, err := m.storage.Copy(ctx, uploadedObject, objectName)
+			if err != nil {
+				return fmt.Errorf("error copying blob: %v", err)
+			}
+			created = true
+			blob := models.Blob{Hash: hash, ObjectName: objectName, Size: size, RefCount: 1}
+			if result := tx.Create(&blob); result.Error != nil {
+				return fmt.Errorf("error saving blob: %v", result.Error)
+			}
+		}
+
+		if err := save(tx, objectName); err != nil {
+			return err
+		}
+		return outbox.DeleteObject(tx, uploadedObject)
+	})
+	if err != nil {
+		if created {
+			m.storage.Delete(ctx, objectName)
+		}
+		return "", err
+	}
+	m.outbox.Notify()
+
+	return objectName, nil
+}
+
+// Acquire adds a reference to an existing blob.
+func (m *Manager) Acquire(ctx context.Context, hash string) error {
+	unlock, err := m.lock(ctx, hash)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	result := m.db.Model(&models.Blob{}).Where("hash = ?", hash).UpdateColumn("ref_count", gorm.Expr("ref_count + 1"))
+	if result.Error != nil {
+		return fmt.Errorf("error updating blob: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errBlobNotFound
+	}
+	return nil
+}
+
+// Release drops a reference to a blob and deletes the blob once nothing
+// refers to it any more.
+func (m *Manager) Release(ctx context.Context, hash string) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		return ReleaseTx(tx, hash)
+	})
+	m.outbox.Notify()
+	return err
+}
+
+// ReleaseTx is Release inside the transaction tx. The row is deleted with the
+// transaction; the object is removed through the outbox once it commits.
+func ReleaseTx(tx *gorm.DB, hash string) error {
+	result := tx.Model(&models.Blob{}).Where("hash = ?", hash).UpdateColumn("ref_count", gorm.Expr("ref_count - 1"))
+	if result.Error != nil {
+		return fmt.Errorf("error updating blob: %v", result.Error)
+	}
+
+	result = tx.Where("hash = ? AND ref_count <= 0", hash).Delete(&models.Blob{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting blob: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	return outbox.Enqueue(tx, KindDeleteBlob, deleteBlobPayload{Hash: hash})
+}
+
+// Drop removes content that a file or version no longer needs: a reference
+// to its blob, or the object itself when the content isn't deduplicated.
+func (m *Manager) Drop(ctx context.Context, objectName string, hash string) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		return DropTx(tx, objectName, hash)
+	})
+	m.outbox.Notify()
+	return err
+}
+
+// DropTx is Drop inside the transaction tx.
+func DropTx(tx *gorm.DB, objectName string, hash string) error {
+	if hash != "" {
+		return ReleaseTx(tx, hash)
+	}
+	return outbox.DeleteObject(tx, objectName)
+}
+
+type deleteBlobPayload struct {
+	Hash string `json:"hash"`
+}
+
+// deleteBlob removes the object of a released blob, unless an upload stored
+// the same content again after the row was deleted.
+func (m *Manager) deleteBlob(ctx context.Context, payload []byte) error {
+	var data deleteBlobPayload
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return err
+	}
+
+	unlock, err := m.lock(ctx, data.Hash)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var count int64
+	if result := m.db.Model(&models.Blob{}).Where("hash = ?", data.Hash).Count(&count); result.Error != nil {
+		return fmt.Errorf("error loading blob: %v", result.Error)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if err := m.storage.Delete(ctx, ObjectName(data.Hash)); err != nil {
+		return fmt.Errorf("error deleting blob object: %v", err)
+	}
+	return nil
+}
+
+// lock serialises reference counting on one blob across server instances.
+func (m *Manager) lock(ctx context.Context, hash string) (func(), error) {
+	key := "blob_lock:" + hash
+	deadline := time.Now().Add(lockWait)
+	for {
+		locked, err := m.cache.SetNX(ctx, key, 1, lockTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error locking blob: %v", err)
+		}
+		if locked {
+			return func() { m.cache.Del(context.Background(), key) }, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for blob lock")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockBackoff):
+		}
+	}
+}
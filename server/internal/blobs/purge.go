@@ -0,0 +1,50 @@
+// internal/blobs/purge.go
+package blobs
+
+import (
+	"fmt"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"gorm.io/gorm"
+)
+
+// PurgeFile permanently deletes the file's versions, share links,
+// permissions and metadata, whether or not the file is in the trash. The rows
+// go in one transaction; objects and cache entries are removed through the
+// outbox once it commits, keeping blobs other files still use. The caller
+// notifies the outbox.
+func PurgeFile(db *gorm.DB, file *models.FileMetadata) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var versions []models.FileVersion
+		if result := tx.Where("file_id = ?", file.ID).Find(&versions); result.Error != nil {
+			return fmt.Errorf("error finding versions: %v", result.Error)
+		}
+		for _, version := range versions {
+			if err := DropTx(tx, version.ObjectName, version.ContentHash); err != nil {
+				return fmt.Errorf("error deleting version content: %v", err)
+			}
+			if result := tx.Delete(&version); result.Error != nil {
+				return fmt.Errorf("error deleting version metadata: %v", result.Error)
+			}
+		}
+
+		if result := tx.Where("file_id = ?", file.ID).Delete(&models.ShareLink{}); result.Error != nil {
+			return fmt.Errorf("error deleting share links: %v", result.Error)
+		}
+
+		if result := tx.Where("resource_type = ? AND resource_id = ?", models.ResourceFile, file.ID).Delete(&models.Permission{}); result.Error != nil {
+			return fmt.Errorf("error deleting permissions: %v", result.Error)
+		}
+
+		if err := DropTx(tx, file.StorageKey(), file.ContentHash); err != nil {
+			return fmt.Errorf("error deleting file content: %v", err)
+		}
+
+		if result := tx.Unscoped().Delete(file); result.Error != nil {
+			return fmt.Errorf("error deleting file metadata: %v", result.Error)
+		}
+
+		return outbox.InvalidateFileCaches(tx, file.UserID, file.ID)
+	})
+}
@@ -0,0 +1,346 @@
+// internal/config/config.go
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config holds every setting the server reads. Each field can come from
+// the config file (by its key), an environment variable or a flag named
+// after its dotted key, such as -database.host.
+type Config struct {
+	Server    ServerConfig    `key:"server"`
+	Database  DatabaseConfig  `key:"database"`
+	Storage   StorageConfig   `key:"storage"`
+	Cache     CacheConfig     `key:"cache"`
+	Auth      AuthConfig      `key:"auth"`
+	Limits    LimitsConfig    `key:"limits"`
+	Timeouts  TimeoutsConfig  `key:"timeouts"`
+	Reconcile ReconcileConfig `key:"reconcile"`
+}
+
+type ServerConfig struct {
+	Addr string `key:"addr" env:"LISTEN_ADDR"`
+	// Prefix is put in front of every route, such as "/files"
+	Prefix string `key:"prefix" env:"API_PREFIX"`
+	// PublicURL is where clients reach the server, without the prefix. It
+	// is used for share links and tus upload URLs.
+	PublicURL string `key:"public_url" env:"API_URL"`
+	// Embedded runs on SQLite, the in-memory cache and local storage under
+	// DataDir unless those are set explicitly
+	Embedded bool   `key:"embedded" env:"EMBEDDED"`
+	DataDir  string `key:"data_dir" env:"DATA_DIR"`
+}
+
+type DatabaseConfig struct {
+	// Driver is "postgres" or "sqlite"
+	Driver   string `key:"driver" env:"DB_DRIVER"`
+	Host     string `key:"host" env:"DB_HOST"`
+	Port     int    `key:"port" env:"DB_PORT"`
+	User     string `key:"user" env:"DB_USER"`
+	Password string `key:"password" env:"DB_PASSWORD"`
+	Name     string `key:"name" env:"DB_NAME"`
+	SSLMode  string `key:"sslmode" env:"DB_SSLMODE"`
+	TimeZone string `key:"timezone" env:"DB_TIMEZONE"`
+	// SQLitePath is the database file when Driver is "sqlite"
+	SQLitePath string `key:"sqlite_path" env:"SQLITE_PATH"`
+}
+
+type StorageConfig struct {
+	// Backend is "s3", "local" or "memory"
+	Backend   string   `key:"backend" env:"STORAGE_BACKEND"`
+	LocalPath string   `key:"local_path" env:"STORAGE_LOCAL_PATH"`
+	S3        S3Config `key:"s3"`
+}
+
+type S3Config struct {
+	Endpoint  string `key:"endpoint" env:"S3_ENDPOINT"`
+	AccessKey string `key:"access_key" env:"S3_ACCESS_KEY"`
+	SecretKey string `key:"secret_key" env:"S3_SECRET_KEY"`
+	Bucket    string `key:"bucket" env:"S3_BUCKET_NAME"`
+	Region    string `key:"region" env:"S3_REGION"`
+	UseSSL    bool   `key:"use_ssl" env:"S3_USE_SSL"`
+	// CAFile is a PEM bundle to trust on top of the system roots, for
+	// endpoints with a private certificate authority
+	CAFile string `key:"ca_file" env:"S3_CA_FILE"`
+	// PathStyle puts the bucket in the path instead of the host name, which
+	// most S3 compatible servers need when they have no wildcard DNS
+	PathStyle bool `key:"path_style" env:"S3_PATH_STYLE"`
+}
+
+type CacheConfig struct {
+	// Backend is "redis" or "memory"
+	Backend  string `key:"backend" env:"CACHE_BACKEND"`
+	Host     string `key:"host" env:"REDIS_HOST"`
+	Port     int    `key:"port" env:"REDIS_PORT"`
+	Password string `key:"password" env:"REDIS_PASSWORD"`
+	DB       int    `key:"db" env:"REDIS_DB"`
+}
+
+type AuthConfig struct {
+	JWTSecret string `key:"jwt_secret" env:"JWT_SECRET"`
+	// Access tokens can't be checked against the database, so they are
+	// short lived and renewed with a refresh token. A refresh token lasts
+	// RefreshTokenTTL from its last use.
+	AccessTokenTTL  time.Duration `key:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `key:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+	// AdminEmails may call the admin routes
+	AdminEmails []string   `key:"admin_emails" env:"ADMIN_EMAILS"`
+	OIDC        OIDCConfig `key:"oidc"`
+}
+
+// OIDCConfig enables login through an OpenID Connect provider. It is off
+// unless Issuer is set.
+type OIDCConfig struct {
+	// Issuer is the provider's issuer URL; its settings are discovered
+	// from Issuer + "/.well-known/openid-configuration"
+	Issuer       string `key:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string `key:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret string `key:"client_secret" env:"OIDC_CLIENT_SECRET"`
+	// RedirectURL is the callback registered with the provider, the
+	// server's public URL followed by the prefix and /auth/oidc/callback
+	RedirectURL string   `key:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes      []string `key:"scopes" env:"OIDC_SCOPES"`
+	// ClientURL is where the browser is sent after logging in, with the
+	// tokens or the error in the URL fragment. Without it the callback
+	// answers with JSON.
+	ClientURL string `key:"client_url" env:"OIDC_CLIENT_URL"`
+}
+
+type LimitsConfig struct {
+	// DefaultQuotaMB applies to users without their own quota
+	DefaultQuotaMB     int64 `key:"default_quota_mb" env:"DEFAULT_QUOTA_MB"`
+	TrashRetentionDays int   `key:"trash_retention_days" env:"TRASH_RETENTION_DAYS"`
+	// Each user may make RateLimitBurst requests at once, and gets one more
+	// every RateLimitRefill
+	RateLimitBurst  int           `key:"rate_limit_burst" env:"RATE_LIMIT_BURST"`
+	RateLimitRefill time.Duration `key:"rate_limit_refill" env:"RATE_LIMIT_REFILL"`
+}
+
+type TimeoutsConfig struct {
+	ReadHeader time.Duration `key:"read_header" env:"HTTP_READ_HEADER_TIMEOUT"`
+	Idle       time.Duration `key:"idle" env:"HTTP_IDLE_TIMEOUT"`
+	// Upload bounds how long a single upload request may take
+	Upload time.Duration `key:"upload" env:"UPLOAD_TIMEOUT"`
+}
+
+type ReconcileConfig struct {
+	IntervalHours int `key:"interval_hours" env:"RECONCILE_INTERVAL_HOURS"`
+	// Objects younger than this may belong to an upload whose metadata
+	// isn't saved yet, so they are never reported as orphans
+	GraceHours int           `key:"grace_hours" env:"RECONCILE_GRACE_HOURS"`
+	AutoRepair bool          `key:"auto_repair" env:"RECONCILE_AUTO_REPAIR"`
+	Timeout    time.Duration `key:"timeout" env:"RECONCILE_TIMEOUT"`
+}
+
+// Default returns the settings used when nothing is configured. It still
+// needs a JWT secret and database and S3 credentials to pass Validate.
+func Default() Config {
+	var c Config
+	c.applyDefaults()
+	return c
+}
+
+// applyDefaults fills in every setting that is still unset. In embedded
+// mode the database, cache and storage default to their local variants.
+func (c *Config) applyDefaults() {
+	setDefault(&c.Server.Addr, "0.0.0.0:8080")
+	setDefault(&c.Server.DataDir, "./data")
+
+	if c.Server.Embedded {
+		setDefault(&c.Database.Driver, "sqlite")
+		setDefault(&c.Database.SQLitePath, filepath.Join(c.Server.DataDir, "go-store.db"))
+		setDefault(&c.Cache.Backend, "memory")
+		setDefault(&c.Storage.Backend, "local")
+		setDefault(&c.Storage.LocalPath, c.Server.DataDir)
+	}
+
+	setDefault(&c.Database.Driver, "postgres")
+	setDefault(&c.Database.Host, "db")
+	setDefault(&c.Database.Port, 5432)
+	setDefault(&c.Database.SSLMode, "disable")
+	setDefault(&c.Database.TimeZone, "Asia/Kolkata")
+	setDefault(&c.Database.SQLitePath, "./data/go-store.db")
+
+	setDefault(&c.Storage.Backend, "s3")
+	setDefault(&c.Storage.LocalPath, "./data")
+	setDefault(&c.Storage.S3.Region, "ap-south-1")
+
+	setDefault(&c.Cache.Backend, "redis")
+	setDefault(&c.Cache.Port, 6379)
+
+	setDefault(&c.Auth.AccessTokenTTL, 15*time.Minute)
+	setDefault(&c.Auth.RefreshTokenTTL, 30*24*time.Hour)
+	if len(c.Auth.OIDC.Scopes) == 0 {
+		c.Auth.OIDC.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	setDefault(&c.Limits.DefaultQuotaMB, 10*1024)
+	setDefault(&c.Limits.TrashRetentionDays, 30)
+	setDefault(&c.Limits.RateLimitBurst, 500)
+	setDefault(&c.Limits.RateLimitRefill, time.Minute)
+
+	setDefault(&c.Timeouts.ReadHeader, 10*time.Second)
+	setDefault(&c.Timeouts.Idle, 2*time.Minute)
+	setDefault(&c.Timeouts.Upload, 30*time.Minute)
+
+	setDefault(&c.Reconcile.IntervalHours, 24)
+	setDefault(&c.Reconcile.GraceHours, 24)
+	setDefault(&c.Reconcile.Timeout, 30*time.Minute)
+}
+
+func setDefault[T comparable](field *T, value T) {
+	var zero T
+	if *field == zero {
+		*field = value
+	}
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.PublicURL != "" {
+		checkURL(fail, "server.public_url (API_URL)", c.Server.PublicURL)
+	}
+
+	switch c.Database.Driver {
+	case "postgres":
+		if c.Database.Host == "" {
+			fail("database.host (DB_HOST) is required")
+		}
+		if c.Database.User == "" {
+			fail("database.user (DB_USER) is required")
+		}
+		if c.Database.Name == "" {
+			fail("database.name (DB_NAME) is required")
+		}
+		if !oneOf(c.Database.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full") {
+			fail("database.sslmode (DB_SSLMODE) must be one of disable, allow, prefer, require, verify-ca or verify-full, got %q", c.Database.SSLMode)
+		}
+		if _, err := time.LoadLocation(c.Database.TimeZone); err != nil {
+			fail("database.timezone (DB_TIMEZONE) is not a known time zone: %q", c.Database.TimeZone)
+		}
+	case "sqlite":
+	default:
+		fail("database.driver (DB_DRIVER) must be postgres or sqlite, got %q", c.Database.Driver)
+	}
+	checkPort(fail, "database.port (DB_PORT)", c.Database.Port)
+
+	switch c.Storage.Backend {
+	case "s3":
+		s3 := c.Storage.S3
+		if s3.Endpoint == "" {
+			fail("storage.s3.endpoint (S3_ENDPOINT) is required")
+		} else if strings.Contains(s3.Endpoint, "://") {
+			fail("storage.s3.endpoint (S3_ENDPOINT) must be a host and port without a scheme, set storage.s3.use_ssl (S3_USE_SSL) for https")
+		}
+		if s3.AccessKey == "" || s3.SecretKey == "" {
+			fail("storage.s3.access_key (S3_ACCESS_KEY) and storage.s3.secret_key (S3_SECRET_KEY) are required")
+		}
+		if s3.Bucket == "" {
+			fail("storage.s3.bucket (S3_BUCKET_NAME) is required")
+		}
+		if s3.CAFile != "" {
+			if !s3.UseSSL {
+				fail("storage.s3.ca_file (S3_CA_FILE) needs storage.s3.use_ssl (S3_USE_SSL)")
+			}
+			if _, err := os.Stat(s3.CAFile); err != nil {
+				fail("storage.s3.ca_file (S3_CA_FILE): %v", err)
+			}
+		}
+	case "local", "memory":
+	default:
+		fail("storage.backend (STORAGE_BACKEND) must be s3, local or memory, got %q", c.Storage.Backend)
+	}
+
+	switch c.Cache.Backend {
+	case "redis":
+		if c.Cache.Host == "" {
+			fail("cache.host (REDIS_HOST) is required")
+		}
+	case "memory":
+	default:
+		fail("cache.backend (CACHE_BACKEND) must be redis or memory, got %q", c.Cache.Backend)
+	}
+	checkPort(fail, "cache.port (REDIS_PORT)", c.Cache.Port)
+	if c.Cache.DB < 0 {
+		fail("cache.db (REDIS_DB) must not be negative")
+	}
+
+	if c.Auth.JWTSecret == "" {
+		fail("auth.jwt_secret (JWT_SECRET) is required")
+	}
+	checkPositive(fail, "auth.access_token_ttl (ACCESS_TOKEN_TTL)", c.Auth.AccessTokenTTL)
+	if c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+		fail("auth.refresh_token_ttl (REFRESH_TOKEN_TTL) must be longer than auth.access_token_ttl (ACCESS_TOKEN_TTL)")
+	}
+	if oidc := c.Auth.OIDC; oidc.Issuer != "" {
+		checkURL(fail, "auth.oidc.issuer (OIDC_ISSUER)", oidc.Issuer)
+		if oidc.ClientID == "" {
+			fail("auth.oidc.client_id (OIDC_CLIENT_ID) is required")
+		}
+		if oidc.RedirectURL == "" {
+			fail("auth.oidc.redirect_url (OIDC_REDIRECT_URL) is required")
+		} else {
+			checkURL(fail, "auth.oidc.redirect_url (OIDC_REDIRECT_URL)", oidc.RedirectURL)
+		}
+		if oidc.ClientURL != "" {
+			checkURL(fail, "auth.oidc.client_url (OIDC_CLIENT_URL)", oidc.ClientURL)
+		}
+		if !oneOf("openid", oidc.Scopes...) {
+			fail("auth.oidc.scopes (OIDC_SCOPES) must include openid")
+		}
+	}
+
+	checkPositive(fail, "limits.default_quota_mb (DEFAULT_QUOTA_MB)", c.Limits.DefaultQuotaMB)
+	checkPositive(fail, "limits.trash_retention_days (TRASH_RETENTION_DAYS)", c.Limits.TrashRetentionDays)
+	checkPositive(fail, "limits.rate_limit_burst (RATE_LIMIT_BURST)", c.Limits.RateLimitBurst)
+	checkPositive(fail, "limits.rate_limit_refill (RATE_LIMIT_REFILL)", c.Limits.RateLimitRefill)
+
+	checkPositive(fail, "timeouts.read_header (HTTP_READ_HEADER_TIMEOUT)", c.Timeouts.ReadHeader)
+	checkPositive(fail, "timeouts.idle (HTTP_IDLE_TIMEOUT)", c.Timeouts.Idle)
+	checkPositive(fail, "timeouts.upload (UPLOAD_TIMEOUT)", c.Timeouts.Upload)
+
+	checkPositive(fail, "reconcile.interval_hours (RECONCILE_INTERVAL_HOURS)", c.Reconcile.IntervalHours)
+	checkPositive(fail, "reconcile.grace_hours (RECONCILE_GRACE_HOURS)", c.Reconcile.GraceHours)
+	checkPositive(fail, "reconcile.timeout (RECONCILE_TIMEOUT)", c.Reconcile.Timeout)
+
+	return errors.Join(errs...)
+}
+
+func checkPort(fail func(string, ...any), name string, port int) {
+	if port < 1 || port > 65535 {
+		fail("%s must be between 1 and 65535, got %d", name, port)
+	}
+}
+
+func checkURL(fail func(string, ...any), name string, value string) {
+	if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("%s must be an http or https URL, got %q", name, value)
+	}
+}
+
+func checkPositive[T int | int64 | time.Duration](fail func(string, ...any), name string, value T) {
+	if value <= 0 {
+		fail("%s must be greater than zero, got %v", name, value)
+	}
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+// internal/config/load.go
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads the configuration from, in increasing priority: the YAML or
+// TOML file named by -config or CONFIG_FILE, environment variables and
+// command line flags. Anything left unset gets its default, and the
+// result is validated.
+func Load(args []string) (Config, error) {
+	var c Config
+	fields := c.fields()
+
+	fs := flag.NewFlagSet("go-store", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config `file`")
+	var flagged [][2]string
+	for _, f := range fields {
+		path := f.path
+		fs.Func(path, "overrides "+f.env, func(value string) error {
+			flagged = append(flagged, [2]string{path, value})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *configFile != "" {
+		if err := c.loadFile(*configFile, fields); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, f := range fields {
+		value, ok := os.LookupEnv(f.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", f.env, err)
+		}
+	}
+
+	byPath := indexFields(fields)
+	for _, kv := range flagged {
+		if err := byPath[kv[0]].set(kv[1]); err != nil {
+			return Config{}, fmt.Errorf("-%s: %w", kv[0], err)
+		}
+	}
+
+	c.applyDefaults()
+	if err := c.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return c, nil
+}
+
+// field is one setting, addressed by its dotted key.
+type field struct {
+	path  string
+	env   string
+	value reflect.Value
+}
+
+func (c *Config) fields() []field {
+	var fields []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			path := prefix + sf.Tag.Get("key")
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), path+".")
+				continue
+			}
+			fields = append(fields, field{path: path, env: sf.Tag.Get("env"), value: v.Field(i)})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return fields
+}
+
+func indexFields(fields []field) map[string]field {
+	byPath := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byPath[f.path] = f
+	}
+	return byPath
+}
+
+// set parses value the same way whether it came from a file, the
+// environment or a flag. Lists are separated by commas.
+func (f field) set(value string) error {
+	switch f.value.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, use a value such as 30s or 2h", value)
+		}
+		f.value.SetInt(int64(d))
+	case []string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	case string:
+		f.value.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, use true or false", value)
+		}
+		f.value.SetBool(b)
+	case int, int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		f.value.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.value.Type())
+	}
+	return nil
+}
+
+// loadFile reads a YAML or TOML file, picked by its extension, whose
+// sections and keys mirror Config.
+func (c *Config) loadFile(path string, fields []field) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return applyValues(values, "", indexFields(fields), path)
+}
+
+func applyValues(values map[string]any, prefix string, byPath map[string]field, file string) error {
+	// Sorted so the first error is the same on every run
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := prefix + key
+		switch value := values[key].(type) {
+		case map[string]any:
+			if err := applyValues(value, path+".", byPath, file); err != nil {
+				return err
+			}
+			continue
+		case nil:
+			continue
+		default:
+			f, ok := byPath[path]
+			if !ok {
+				return fmt.Errorf("%s: unknown setting %q", file, path)
+			}
+			if err := f.set(scalarString(value)); err != nil {
+				return fmt.Errorf("%s: %s: %w", file, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// scalarString turns a decoded file value into the text form set expects.
+func scalarString(value any) string {
+	if list, ok := value.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,96 @@
+// internal/handlers/adminHandler.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/ayushh2k/go-store-s3/server/internal/workers"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ReconcileStorage compares the bucket with the database and returns the
+// report. It only reports unless repair=true is passed. The run isn't tied
+// to the request, so a dropped connection can't stop a repair halfway.
+func (h *Handler) ReconcileStorage(c *gin.Context) {
+	repair := c.Query("repair") == "true"
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.Reconcile.Timeout)
+	defer cancel()
+
+	report, err := h.workers.Reconcile(ctx, repair)
+	if errors.Is(err, workers.ErrReconcileRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A reconciliation is already running"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to reconcile storage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile storage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// RecordFileChecksums records the checksums of a file uploaded before they
+// were kept. The content is read back and only trusted while its size still
+// matches; files that already have both checksums are left alone.
+func (h *Handler) RecordFileChecksums(c *gin.Context) {
+	fileID, err := uuid.Parse(c.Param("file_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+	var fileMetadata models.FileMetadata
+	if result := h.db.Unscoped().First(&fileMetadata, "id = ?", fileID); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	check, err := h.checkFile(ctx, &fileMetadata)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "File content is missing"})
+			return
+		}
+		log.Printf("Failed to read object: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record checksums"})
+		return
+	}
+	if len(check.Mismatches) > 0 {
+		log.Printf("Integrity check failed for file %s: %v", fileMetadata.ID, check.Mismatches)
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "File content does not match its metadata",
+			"mismatches": check.Mismatches,
+		})
+		return
+	}
+
+	recorded := false
+	if check.Expected.SHA256 == "" || check.Expected.CRC32C == "" {
+		result := h.db.Model(&fileMetadata).Updates(map[string]interface{}{
+			"checksum_sha256":  check.Actual.SHA256,
+			"checksum_crc32_c": check.Actual.CRC32C,
+		})
+		if result.Error != nil {
+			log.Printf("Failed to record checksums: %v", result.Error)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record checksums"})
+			return
+		}
+		recorded = true
+		h.invalidateFileCaches(context.Background(), fileMetadata.UserID, fileMetadata.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":         fileMetadata.ID,
+		"recorded":        recorded,
+		"checksum_sha256": check.Actual.SHA256,
+		"checksum_crc32c": check.Actual.CRC32C,
+	})
+}
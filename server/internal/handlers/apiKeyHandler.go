@@ -0,0 +1,139 @@
+// internal/handlers/apiKeyHandler.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`
+	FolderID  string `json:"folder_id"`
+	ExpiresIn int    `json:"expires_in"` // minutes, 0 never expires
+}
+
+// CreateAPIKey creates a key for automation. The key is only ever shown in
+// this response.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var request CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key name"})
+		return
+	}
+	if !models.ValidAPIKeyScope(request.Scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scope must be read, upload or full"})
+		return
+	}
+	if request.ExpiresIn < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry"})
+		return
+	}
+	folderID, err := h.resolveFolderID(userObj.ID, request.FolderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	apiKey := models.APIKey{
+		UserID:   userObj.ID,
+		Name:     name,
+		Scope:    request.Scope,
+		FolderID: folderID,
+	}
+	if request.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(request.ExpiresIn) * time.Minute)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	key, err := h.tokens.CreateAPIKey(&apiKey)
+	if err != nil {
+		log.Printf("Failed to create API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	log.Printf("Audit: user %s created API key %s with scope %s", userObj.ID, apiKey.ID, apiKey.Scope)
+
+	response := apiKeyResponse(apiKey)
+	response["key"] = key
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListAPIKeys returns the user's active keys, without the keys themselves.
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	apiKeys, err := h.tokens.APIKeys(userObj.ID)
+	if err != nil {
+		log.Printf("Failed to retrieve API keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys"})
+		return
+	}
+
+	response := make([]gin.H, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		response[i] = apiKeyResponse(apiKey)
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": response})
+}
+
+// RevokeAPIKey stops one of the user's keys from working.
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	err = h.tokens.RevokeAPIKey(userObj.ID, keyID)
+	if errors.Is(err, tokens.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to revoke API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	log.Printf("Audit: user %s revoked API key %s", userObj.ID, keyID)
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+func apiKeyResponse(apiKey models.APIKey) gin.H {
+	return gin.H{
+		"id":           apiKey.ID,
+		"name":         apiKey.Name,
+		"prefix":       apiKey.Prefix,
+		"scope":        apiKey.Scope,
+		"folder_id":    apiKey.FolderID,
+		"expires_at":   apiKey.ExpiresAt,
+		"last_used_at": apiKey.LastUsedAt,
+		"created_at":   apiKey.CreatedAt,
+	}
+}
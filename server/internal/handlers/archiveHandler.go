@@ -0,0 +1,279 @@
+// internal/handlers/archiveHandler.go
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/authz"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	maxArchiveFiles      = 1000
+	archiveManifestName  = "MANIFEST.json"
+	archiveFormatZip     = "zip"
+	archiveFormatTarGzip = "tar.gz"
+)
+
+type ArchiveRequest struct {
+	FileIDs []string            `json:"file_ids"`
+	Query   *SearchFilesRequest `json:"query"`
+	Format  string              `json:"format"`
+	Name    string              `json:"name"`
+}
+
+type archiveManifestEntry struct {
+	Name        string    `json:"name"`
+	FileID      uuid.UUID `json:"file_id"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"content_type"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// archiveWriter hides the differences between the zip and tar.gz writers.
+type archiveWriter interface {
+	Create(name string, size int64, modified time.Time) (io.Writer, error)
+	Close() error
+}
+
+func (h *Handler) DownloadArchive(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var request ArchiveRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if request.Format == "" {
+		request.Format = archiveFormatZip
+	}
+	if request.Format != archiveFormatZip && request.Format != archiveFormatTarGzip {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be zip or tar.gz"})
+		return
+	}
+	if (len(request.FileIDs) == 0) == (request.Query == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either file_ids or query"})
+		return
+	}
+
+	var files []models.FileMetadata
+	if request.Query != nil {
+		query, err := h.buildSearchQuery(userObj.ID, *request.Query)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if result := query.Order("file_name").Limit(maxArchiveFiles + 1).Find(&files); result.Error != nil {
+			log.Printf("Failed to search files: %v", result.Error)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search files"})
+			return
+		}
+	} else {
+		if len(request.FileIDs) > maxArchiveFiles {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An archive can hold at most %d files", maxArchiveFiles)})
+			return
+		}
+		fileUUIDs := make([]uuid.UUID, 0, len(request.FileIDs))
+		for _, fileID := range request.FileIDs {
+			fileUUID, err := uuid.Parse(fileID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+				return
+			}
+			fileUUIDs = append(fileUUIDs, fileUUID)
+		}
+		result := h.db.Where("id IN ?", fileUUIDs).Order("file_name").Find(&files)
+		if result.Error != nil {
+			log.Printf("Failed to retrieve files: %v", result.Error)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve files"})
+			return
+		}
+		if len(files) != len(uniqueUUIDs(fileUUIDs)) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+
+		// Files shared with the user can be archived too
+		for _, file := range files {
+			role, err := authz.FileRole(h.db, userObj.ID, &file)
+			if err != nil {
+				log.Printf("Failed to check file permissions: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+				return
+			}
+			if !authz.RoleAllows(role, authz.ActionView) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+				return
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No files to archive"})
+		return
+	}
+	if len(files) > maxArchiveFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An archive can hold at most %d files", maxArchiveFiles)})
+		return
+	}
+
+	archiveName := path.Base(request.Name)
+	if archiveName == "." || archiveName == "/" {
+		archiveName = "files"
+	}
+	archiveName = strings.TrimSuffix(archiveName, "."+request.Format) + "." + request.Format
+
+	var archive archiveWriter
+	if request.Format == archiveFormatZip {
+		c.Header("Content-Type", "application/zip")
+		archive = &zipArchive{writer: zip.NewWriter(c.Writer)}
+	} else {
+		c.Header("Content-Type", "application/gzip")
+		gzipWriter := gzip.NewWriter(c.Writer)
+		archive = &tarGzipArchive{gzip: gzipWriter, writer: tar.NewWriter(gzipWriter)}
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archiveName}))
+	c.Status(http.StatusOK)
+
+	// From here on the response is committed, so failures can only cut the archive short
+	ctx := c.Request.Context()
+	usedNames := map[string]bool{archiveManifestName: true}
+	manifest := make([]archiveManifestEntry, 0, len(files))
+
+	for _, file := range files {
+		name := uniqueArchiveName(path.Base(file.FileName), usedNames)
+
+		object, objectInfo, err := h.storage.Get(ctx, file.StorageKey(), storage.GetOptions{})
+		if err != nil {
+			log.Printf("Failed to get object %s for archive: %v", file.FileName, err)
+			c.Abort()
+			return
+		}
+
+		entry, err := archive.Create(name, objectInfo.Size, file.UploadedAt)
+		if err != nil {
+			object.Close()
+			log.Printf("Failed to add %s to archive: %v", name, err)
+			c.Abort()
+			return
+		}
+
+		checksum := sha256.New()
+		_, err = io.Copy(io.MultiWriter(entry, checksum), object)
+		object.Close()
+		if err != nil {
+			log.Printf("Failed to write %s to archive: %v", name, err)
+			c.Abort()
+			return
+		}
+
+		manifest = append(manifest, archiveManifestEntry{
+			Name:        name,
+			FileID:      file.ID,
+			Size:        objectInfo.Size,
+			SHA256:      hex.EncodeToString(checksum.Sum(nil)),
+			ContentType: file.ContentType,
+			UploadedAt:  file.UploadedAt,
+		})
+	}
+
+	manifestJSON, err := json.MarshalIndent(gin.H{
+		"created_at": time.Now(),
+		"files":      manifest,
+	}, "", "  ")
+	if err != nil {
+		log.Printf("Failed to marshal archive manifest: %v", err)
+		c.Abort()
+		return
+	}
+	entry, err := archive.Create(archiveManifestName, int64(len(manifestJSON)), time.Now())
+	if err == nil {
+		_, err = entry.Write(manifestJSON)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		log.Printf("Failed to finish archive: %v", err)
+		c.Abort()
+	}
+}
+
+// uniqueArchiveName turns a second "report.pdf" into "report (1).pdf".
+func uniqueArchiveName(name string, usedNames map[string]bool) string {
+	candidate := name
+	extension := path.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+	for i := 1; usedNames[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, extension)
+	}
+	usedNames[candidate] = true
+	return candidate
+}
+
+func uniqueUUIDs(ids []uuid.UUID) map[uuid.UUID]bool {
+	unique := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	return unique
+}
+
+type zipArchive struct {
+	writer *zip.Writer
+}
+
+func (a *zipArchive) Create(name string, size int64, modified time.Time) (io.Writer, error) {
+	return a.writer.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+}
+
+func (a *zipArchive) Close() error {
+	return a.writer.Close()
+}
+
+type tarGzipArchive struct {
+	gzip   *gzip.Writer
+	writer *tar.Writer
+}
+
+func (a *tarGzipArchive) Create(name string, size int64, modified time.Time) (io.Writer, error) {
+	err := a.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modified,
+	})
+	return a.writer, err
+}
+
+func (a *tarGzipArchive) Close() error {
+	if err := a.writer.Close(); err != nil {
+		return err
+	}
+	return a.gzip.Close()
+}
@@ -2,18 +2,17 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"os"
-	"time"
 
-	"github.com/ayushh2k/go-store-s3/server/internal/initializers"
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func Signup(c *gin.Context) {
+func (h *Handler) Signup(c *gin.Context) {
 	var body struct {
 		Email    string
 		Password string
@@ -41,7 +40,7 @@ func Signup(c *gin.Context) {
 		Password: string(hash),
 	}
 
-	result := initializers.DB.Db.Create(&user)
+	result := h.db.Create(&user)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create user",
@@ -54,7 +53,7 @@ func Signup(c *gin.Context) {
 	})
 }
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	var body struct {
 		Email    string
 		Password string
@@ -69,7 +68,7 @@ func Login(c *gin.Context) {
 
 	// Find the user by email
 	var user models.User
-	result := initializers.DB.Db.Where("email = ?", body.Email).First(&user)
+	result := h.db.Where("email = ?", body.Email).First(&user)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Invalid email or password",
@@ -86,22 +85,134 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Generate a JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(), // Token valid for 30 days
-	})
-
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	// Start a session with a short lived access token and a refresh token
+	pair, err := h.tokens.Issue(user.ID, tokenClient(c))
 	if err != nil {
+		log.Printf("Failed to issue tokens: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to sign token",
 		})
 		return
 	}
 
-	// Respond with the token
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-	})
+	c.JSON(http.StatusOK, tokenResponse(pair))
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh
+// token. Each refresh token works once.
+func (h *Handler) Refresh(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	pair, err := h.tokens.Refresh(c.Request.Context(), body.RefreshToken)
+	switch {
+	case errors.Is(err, tokens.ErrRefreshTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used, the session has been revoked"})
+		return
+	case errors.Is(err, tokens.ErrInvalidRefreshToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	case err != nil:
+		log.Printf("Failed to refresh tokens: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(pair))
+}
+
+// Logout revokes the session the request's access token belongs to.
+func (h *Handler) Logout(c *gin.Context) {
+	claims := currentClaims(c)
+	// A session that is already gone needs no logout
+	err := h.tokens.RevokeSession(c.Request.Context(), claims.UserID, claims.SessionID)
+	if err != nil && !errors.Is(err, tokens.ErrSessionNotFound) {
+		log.Printf("Failed to revoke session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll revokes every session of the user, including the current one.
+func (h *Handler) LogoutAll(c *gin.Context) {
+	claims := currentClaims(c)
+	if err := h.tokens.RevokeAll(c.Request.Context(), claims.UserID); err != nil {
+		log.Printf("Failed to revoke sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// ChangePassword sets a new password and logs out every session. The
+// response carries tokens for a new session, so the client making the
+// change stays logged in.
+func (h *Handler) ChangePassword(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(userObj.Password), []byte(body.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), 10)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	if result := h.db.Model(&userObj).Update("password", string(hash)); result.Error != nil {
+		log.Printf("Failed to update password: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	if err := h.tokens.RevokeAll(c.Request.Context(), userObj.ID); err != nil {
+		log.Printf("Failed to revoke sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed, but failed to log out other sessions"})
+		return
+	}
+	pair, err := h.tokens.Issue(userObj.ID, tokenClient(c))
+	if err != nil {
+		log.Printf("Failed to issue tokens: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(pair))
+}
+
+// tokenClient describes the device making the request, for its session.
+func tokenClient(c *gin.Context) tokens.Client {
+	return tokens.Client{UserAgent: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+}
+
+// tokenResponse is the body returned when a session starts or is
+// refreshed. "token" is the access token, kept under its old name for
+// existing clients.
+func tokenResponse(pair tokens.Pair) gin.H {
+	return gin.H{
+		"token":         pair.AccessToken,
+		"access_token":  pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int64(pair.ExpiresIn.Seconds()),
+	}
 }
@@ -0,0 +1,268 @@
+// internal/handlers/checksumHandler.go
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksums holds the hex encoded digests of a file's content. An empty
+// field means the digest is unknown.
+type checksums struct {
+	SHA256 string
+	CRC32C string
+}
+
+func fileChecksums(fileMetadata *models.FileMetadata) checksums {
+	return checksums{SHA256: fileMetadata.ChecksumSHA256, CRC32C: fileMetadata.ChecksumCRC32C}
+}
+
+func versionChecksums(version *models.FileVersion) checksums {
+	return checksums{SHA256: version.ChecksumSHA256, CRC32C: version.ChecksumCRC32C}
+}
+
+// checksumHasher computes every checksum the server records in one pass.
+type checksumHasher struct {
+	sha256 hash.Hash
+	crc32c hash.Hash32
+}
+
+func newChecksumHasher() *checksumHasher {
+	return &checksumHasher{
+		sha256: sha256.New(),
+		crc32c: crc32.New(crc32cTable),
+	}
+}
+
+func (hasher *checksumHasher) Write(p []byte) (int, error) {
+	hasher.sha256.Write(p)
+	hasher.crc32c.Write(p)
+	return len(p), nil
+}
+
+func (hasher *checksumHasher) Sum() checksums {
+	return checksums{
+		SHA256: hex.EncodeToString(hasher.sha256.Sum(nil)),
+		CRC32C: hex.EncodeToString(hasher.crc32c.Sum(nil)),
+	}
+}
+
+// MarshalBinary saves the hasher's state, so hashing can go on in a later
+// request.
+func (hasher *checksumHasher) MarshalBinary() ([]byte, error) {
+	var state struct {
+		SHA256 []byte `json:"sha256"`
+		CRC32C []byte `json:"crc32c"`
+	}
+	var err error
+	if state.SHA256, err = hasher.sha256.(encoding.BinaryMarshaler).MarshalBinary(); err != nil {
+		return nil, err
+	}
+	if state.CRC32C, err = hasher.crc32c.(encoding.BinaryMarshaler).MarshalBinary(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+// restoreChecksumHasher returns a hasher in the state saved by MarshalBinary,
+// or a new one when there is no state.
+func restoreChecksumHasher(data []byte) (*checksumHasher, error) {
+	hasher := newChecksumHasher()
+	if len(data) == 0 {
+		return hasher, nil
+	}
+	var state struct {
+		SHA256 []byte `json:"sha256"`
+		CRC32C []byte `json:"crc32c"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error decoding checksum state: %v", err)
+	}
+	if err := hasher.sha256.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.SHA256); err != nil {
+		return nil, fmt.Errorf("error restoring sha256 state: %v", err)
+	}
+	if err := hasher.crc32c.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.CRC32C); err != nil {
+		return nil, fmt.Errorf("error restoring crc32c state: %v", err)
+	}
+	return hasher, nil
+}
+
+// expectedChecksums reads the digests a client sent with an upload, from
+// Content-Digest (RFC 9530) or X-Checksum-SHA256, which takes hex or base64.
+func expectedChecksums(c *gin.Context) (checksums, error) {
+	var expected checksums
+
+	if header := c.GetHeader("Content-Digest"); header != "" {
+		for _, member := range strings.Split(header, ",") {
+			algorithm, value, found := strings.Cut(strings.TrimSpace(member), "=")
+			if !found || len(value) < 2 || !strings.HasPrefix(value, ":") || !strings.HasSuffix(value, ":") {
+				return checksums{}, fmt.Errorf("invalid Content-Digest header")
+			}
+			digest, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+			if err != nil {
+				return checksums{}, fmt.Errorf("invalid Content-Digest header")
+			}
+			switch strings.ToLower(algorithm) {
+			case "sha-256":
+				if len(digest) != sha256.Size {
+					return checksums{}, fmt.Errorf("invalid sha-256 digest")
+				}
+				expected.SHA256 = hex.EncodeToString(digest)
+			case "crc32c":
+				if len(digest) != crc32.Size {
+					return checksums{}, fmt.Errorf("invalid crc32c digest")
+				}
+				expected.CRC32C = hex.EncodeToString(digest)
+			}
+		}
+	}
+
+	if header := c.GetHeader("X-Checksum-SHA256"); header != "" {
+		digest, err := hex.DecodeString(header)
+		if err != nil || len(digest) != sha256.Size {
+			digest, err = base64.StdEncoding.DecodeString(header)
+		}
+		if err != nil || len(digest) != sha256.Size {
+			return checksums{}, fmt.Errorf("invalid X-Checksum-SHA256 header")
+		}
+		value := hex.EncodeToString(digest)
+		if expected.SHA256 != "" && expected.SHA256 != value {
+			return checksums{}, fmt.Errorf("Content-Digest and X-Checksum-SHA256 disagree")
+		}
+		expected.SHA256 = value
+	}
+
+	return expected, nil
+}
+
+// mismatchedChecksums lists the algorithms whose expected digest differs from
+// the actual one. Unknown expected digests are skipped.
+func mismatchedChecksums(expected checksums, actual checksums) []string {
+	mismatches := []string{}
+	if expected.SHA256 != "" && expected.SHA256 != actual.SHA256 {
+		mismatches = append(mismatches, "sha256")
+	}
+	if expected.CRC32C != "" && expected.CRC32C != actual.CRC32C {
+		mismatches = append(mismatches, "crc32c")
+	}
+	return mismatches
+}
+
+// setChecksumHeaders advertises the stored checksums of a download. Repr-Digest
+// describes the whole file, so it stays correct for range requests.
+func setChecksumHeaders(c *gin.Context, sums checksums) {
+	var digests []string
+	if sums.SHA256 != "" {
+		c.Header("X-Checksum-SHA256", sums.SHA256)
+		digests = append(digests, "sha-256="+digestValue(sums.SHA256))
+	}
+	if sums.CRC32C != "" {
+		digests = append(digests, "crc32c="+digestValue(sums.CRC32C))
+	}
+	if len(digests) > 0 {
+		c.Header("Repr-Digest", strings.Join(digests, ", "))
+	}
+}
+
+// digestValue turns a hex digest into an RFC 9530 byte sequence.
+func digestValue(hexDigest string) string {
+	digest, _ := hex.DecodeString(hexDigest)
+	return ":" + base64.StdEncoding.EncodeToString(digest) + ":"
+}
+
+// VerifyFile reads the file's content back from storage and compares it with the
+// size and checksums recorded at upload. It only reports; missing checksums
+// are recorded by the admin RecordFileChecksums.
+func (h *Handler) VerifyFile(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+
+	check, err := h.checkFile(c.Request.Context(), &fileMetadata)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Integrity check failed for file %s: content is missing", fileMetadata.ID)
+			c.JSON(http.StatusOK, gin.H{
+				"file_id":    fileMetadata.ID,
+				"valid":      false,
+				"mismatches": []string{"missing"},
+			})
+			return
+		}
+		log.Printf("Failed to read object: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify file"})
+		return
+	}
+
+	valid := len(check.Mismatches) == 0
+	if !valid {
+		log.Printf("Integrity check failed for file %s: %v", fileMetadata.ID, check.Mismatches)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":    fileMetadata.ID,
+		"valid":      valid,
+		"mismatches": check.Mismatches,
+		"expected": gin.H{
+			"file_size":       fileMetadata.FileSize,
+			"checksum_sha256": check.Expected.SHA256,
+			"checksum_crc32c": check.Expected.CRC32C,
+		},
+		"computed": gin.H{
+			"file_size":       check.Size,
+			"checksum_sha256": check.Actual.SHA256,
+			"checksum_crc32c": check.Actual.CRC32C,
+		},
+	})
+}
+
+// fileCheck is the result of reading a file's content back from storage.
+type fileCheck struct {
+	Size       int64
+	Expected   checksums
+	Actual     checksums
+	Mismatches []string
+}
+
+// checkFile hashes the file's content and compares it with the size and
+// checksums recorded at upload.
+func (h *Handler) checkFile(ctx context.Context, fileMetadata *models.FileMetadata) (fileCheck, error) {
+	hasher := newChecksumHasher()
+	size, err := h.hashObject(ctx, fileMetadata.StorageKey(), hasher)
+	if err != nil {
+		return fileCheck{}, err
+	}
+
+	check := fileCheck{Size: size, Expected: fileChecksums(fileMetadata), Actual: hasher.Sum()}
+	check.Mismatches = mismatchedChecksums(check.Expected, check.Actual)
+	if size != fileMetadata.FileSize {
+		check.Mismatches = append(check.Mismatches, "size")
+	}
+	return check, nil
+}
+
+// hashObject streams the object through hasher and returns its size.
+func (h *Handler) hashObject(ctx context.Context, objectName string, hasher io.Writer) (int64, error) {
+	object, _, err := h.storage.Get(ctx, objectName, storage.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer object.Close()
+	return io.Copy(hasher, object)
+}
@@ -3,67 +3,56 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
 
-	"github.com/ayushh2k/go-store-s3/server/internal/initializers"
+	"github.com/ayushh2k/go-store-s3/server/internal/blobs"
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
 )
 
-func DeleteFile(c *gin.Context) {
-	fileID := c.Param("file_id")
-	fileUUID, err := uuid.Parse(fileID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
-		return
-	}
-	var fileMetadata models.FileMetadata
-	result := initializers.DB.Db.First(&fileMetadata, "id = ?", fileUUID)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-		return
-	}
-
-	// Delete the file from S3
-	bucketName := os.Getenv("S3_BUCKET_NAME")
-	objectName := fileMetadata.FileName
-	err = initializers.S3Client.RemoveObject(context.Background(), bucketName, objectName, minio.RemoveObjectOptions{})
-	if err != nil {
-		log.Printf("Failed to delete file from S3: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file from S3"})
-		return
-	}
+// DeleteFile moves the file to the trash. The object stays in S3 until the
+// trash is emptied or the purge worker removes it.
+func (h *Handler) DeleteFile(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
 
-	// Delete the file metadata from the database
-	result = initializers.DB.Db.Delete(&fileMetadata)
-	if result.Error != nil {
-		log.Printf("Failed to delete file metadata: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file metadata"})
+	if err := h.trashFile(context.Background(), &fileMetadata); err != nil {
+		log.Printf("Failed to move file to trash: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
 		return
 	}
 
-	// Invalidate the cache for the user's search results
-	invalidateCache(context.Background(), fileMetadata.UserID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "File moved to trash",
+	})
+}
 
-	// Delete the cache entry for the user's files
-	cacheKey := "files:" + fileMetadata.UserID.String()
-	err = initializers.RedisClient.Del(context.Background(), cacheKey).Err()
+// trashFile soft deletes the file and drops its cache entries.
+func (h *Handler) trashFile(ctx context.Context, fileMetadata *models.FileMetadata) error {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Delete(fileMetadata); result.Error != nil {
+			return fmt.Errorf("error deleting file metadata: %v", result.Error)
+		}
+		return outbox.InvalidateFileCaches(tx, fileMetadata.UserID, fileMetadata.ID)
+	})
 	if err != nil {
-		log.Printf("Failed to delete cache entry: %v", err)
+		return err
 	}
 
-	// Delete the shared link cache entry if it exists
-	sharedLinkCacheKey := "shared_link:" + fileMetadata.ID.String()
-	err = initializers.RedisClient.Del(context.Background(), sharedLinkCacheKey).Err()
-	if err != nil {
-		log.Printf("Failed to delete shared link cache entry: %v", err)
+	h.outbox.Notify()
+	return nil
+}
+
+// purgeFile permanently deletes the file with everything that belongs to it,
+// see blobs.PurgeFile.
+func (h *Handler) purgeFile(ctx context.Context, fileMetadata *models.FileMetadata) error {
+	if err := blobs.PurgeFile(h.db, fileMetadata); err != nil {
+		return err
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "File deleted successfully",
-	})
+	h.outbox.Notify()
+	return nil
 }
@@ -0,0 +1,94 @@
+// internal/handlers/downloadHandler.go
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) DownloadFile(c *gin.Context) {
+
+	fileMetadata := authorizedFile(c)
+
+	setChecksumHeaders(c, fileChecksums(&fileMetadata))
+	h.serveObject(c, fileMetadata.StorageKey(), path.Base(fileMetadata.FileName), fileMetadata.ContentType)
+}
+
+// serveObject streams an object to the client. Range, If-Range,
+// If-None-Match and If-Modified-Since are answered by http.ServeContent,
+// which seeks the object so only the requested bytes are fetched.
+// The disposition query parameter picks inline or attachment (the default).
+func (h *Handler) serveObject(c *gin.Context, objectName, downloadName, contentType string) {
+	objectInfo, ok := h.statObject(c, objectName)
+	if !ok {
+		return
+	}
+	h.serveObjectInfo(c, objectInfo, downloadName, contentType)
+}
+
+// statObject looks up an object before it is served. It writes the error
+// response when it returns false.
+func (h *Handler) statObject(c *gin.Context, objectName string) (storage.ObjectInfo, bool) {
+	objectInfo, err := h.storage.Stat(c.Request.Context(), objectName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return storage.ObjectInfo{}, false
+		}
+		log.Printf("Failed to stat object: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download file"})
+		return storage.ObjectInfo{}, false
+	}
+	return objectInfo, true
+}
+
+// serveObjectInfo is serveObject for an object that was already looked up.
+// It returns the error reading the object from storage, if any.
+func (h *Handler) serveObjectInfo(c *gin.Context, objectInfo storage.ObjectInfo, downloadName, contentType string) error {
+	object := &readErrorRecorder{ReadSeekCloser: storage.NewReadSeeker(c.Request.Context(), h.storage, objectInfo)}
+	defer object.Close()
+
+	disposition := "attachment"
+	if c.Query("disposition") == "inline" {
+		disposition = "inline"
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": downloadName}))
+	c.Header("Cache-Control", "private, no-cache")
+	if objectInfo.ETag != "" {
+		c.Header("ETag", `"`+objectInfo.ETag+`"`)
+	}
+
+	http.ServeContent(c.Writer, c.Request, downloadName, objectInfo.LastModified.Truncate(time.Second), object)
+	if object.err != nil {
+		log.Printf("Failed to read object: %v", object.err)
+	}
+	return object.err
+}
+
+// readErrorRecorder keeps the first error reading the object, which
+// http.ServeContent doesn't report.
+type readErrorRecorder struct {
+	io.ReadSeekCloser
+	err error
+}
+
+func (recorder *readErrorRecorder) Read(p []byte) (int, error) {
+	n, err := recorder.ReadSeekCloser.Read(p)
+	if err != nil && err != io.EOF && recorder.err == nil {
+		recorder.err = err
+	}
+	return n, err
+}
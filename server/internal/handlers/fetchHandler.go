@@ -6,16 +6,13 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
-	"github.com/ayushh2k/go-store-s3/server/internal/initializers"
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-func GetFiles(c *gin.Context) {
+func (h *Handler) GetFiles(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -27,23 +24,47 @@ func GetFiles(c *gin.Context) {
 		return
 	}
 
-	// Check Redis cache first
+	// List a single directory level, the root unless folder_id is given
+	response := gin.H{}
+	folderID, err := h.scopedFolderID(c, userObj.ID, c.Query("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+	folderKey := "root"
+	if folderID != nil {
+		var folder models.Folder
+		h.db.First(&folder, "id = ?", *folderID)
+		response["folder"] = folder
+		response["breadcrumbs"] = h.folderBreadcrumbs(folder)
+		folderKey = folderID.String()
+	} else {
+		response["breadcrumbs"] = []gin.H{}
+	}
+
+	folders, err := h.childFolders(userObj.ID, folderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve folders"})
+		return
+	}
+	response["folders"] = folders
+
+	// Check Redis cache first; every listed folder is a field of the user's hash
 	cacheKey := "files:" + userObj.ID.String()
-	cachedFiles, err := initializers.RedisClient.Get(context.Background(), cacheKey).Result()
+	cachedFiles, err := h.cache.HGet(context.Background(), cacheKey, folderKey).Result()
 	if err == nil {
 		var files []models.FileMetadata
 		err := json.Unmarshal([]byte(cachedFiles), &files)
 		if err == nil {
-			c.JSON(http.StatusOK, gin.H{
-				"files": files,
-			})
+			response["files"] = files
+			c.JSON(http.StatusOK, response)
 			return
 		}
 	}
 
 	// If not in cache, fetch from the database
 	var files []models.FileMetadata
-	result := initializers.DB.Db.Where("user_id = ?", userObj.ID).Find(&files)
+	result := whereFolder(h.db.Where("user_id = ?", userObj.ID), "folder_id", folderID).Find(&files)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve files"})
 		return
@@ -54,58 +75,15 @@ func GetFiles(c *gin.Context) {
 	if err != nil {
 		log.Printf("Failed to marshal files for caching: %v", err)
 	} else {
-		err = initializers.RedisClient.Set(context.Background(), cacheKey, filesJSON, time.Minute*30).Err()
+		err = h.cache.HSet(context.Background(), cacheKey, folderKey, filesJSON).Err()
+		if err == nil {
+			err = h.cache.Expire(context.Background(), cacheKey, time.Minute*30).Err()
+		}
 		if err != nil {
 			log.Printf("Failed to cache files: %v", err)
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"files": files,
-	})
-}
-
-func ShareFile(c *gin.Context) {
-	fileID := c.Param("file_id")
-	fileUUID, err := uuid.Parse(fileID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
-		return
-	}
-
-	// Find the file metadata
-	var fileMetadata models.FileMetadata
-	result := initializers.DB.Db.First(&fileMetadata, "id = ?", fileUUID)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-		return
-	}
-
-	// Generate a pre-signed URL for the object
-	bucketName := os.Getenv("S3_BUCKET_NAME")
-	objectName := fileMetadata.FileName
-	expiresAt := time.Now().Add(30 * time.Minute) // Expires in 30 minutes
-
-	presignedURL, err := initializers.S3Client.PresignedGetObject(context.Background(), bucketName, objectName, time.Until(expiresAt), nil)
-	if err != nil {
-		log.Printf("Failed to generate pre-signed URL: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pre-signed URL"})
-		return
-	}
-
-	// Update the file metadata with the expiration time
-	fileMetadata.ExpiresAt = &expiresAt
-	initializers.DB.Db.Save(&fileMetadata)
-
-	// Cache the shared link in Redis
-	cacheKey := "shared_link:" + fileUUID.String()
-	err = initializers.RedisClient.Set(context.Background(), cacheKey, presignedURL.String(), time.Until(expiresAt)).Err()
-	if err != nil {
-		log.Printf("Failed to cache shared link: %v", err)
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"public_url": presignedURL.String(),
-		"expires_at": expiresAt,
-	})
+	response["files"] = files
+	c.JSON(http.StatusOK, response)
 }
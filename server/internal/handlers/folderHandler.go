@@ -0,0 +1,390 @@
+// internal/handlers/folderHandler.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/authz"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxFolderDepth = 100
+
+var errFolderNotFound = errors.New("Folder not found")
+
+type CreateFolderRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID string `json:"parent_id"`
+}
+
+type RenameFolderRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type MoveRequest struct {
+	FolderID string `json:"folder_id"`
+}
+
+func (h *Handler) CreateFolder(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var request CreateFolderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	name, ok := validFolderName(request.Name)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder name"})
+		return
+	}
+
+	parentID, err := h.scopedFolderID(c, userObj.ID, request.ParentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Parent folder not found"})
+		return
+	}
+	if h.folderNameTaken(userObj.ID, parentID, name, uuid.Nil) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Folder already exists"})
+		return
+	}
+
+	folder := models.Folder{
+		Name:     name,
+		ParentID: parentID,
+		UserID:   userObj.ID,
+	}
+	if result := h.db.Create(&folder); result.Error != nil {
+		log.Printf("Failed to create folder: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder"})
+		return
+	}
+
+	h.invalidateFolderCaches(c.Request.Context(), userObj.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"folder": folder,
+	})
+}
+
+func (h *Handler) ListFolders(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	parentID, err := h.scopedFolderID(c, userObj.ID, c.Query("parent_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	folders, err := h.childFolders(userObj.ID, parentID)
+	if err != nil {
+		log.Printf("Failed to retrieve folders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve folders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"folders": folders,
+	})
+}
+
+func (h *Handler) RenameFolder(c *gin.Context) {
+	folder := authorizedFolder(c)
+
+	var request RenameFolderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	name, ok := validFolderName(request.Name)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder name"})
+		return
+	}
+	if h.folderNameTaken(folder.UserID, folder.ParentID, name, folder.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Folder already exists"})
+		return
+	}
+
+	folder.Name = name
+	if result := h.db.Save(&folder); result.Error != nil {
+		log.Printf("Failed to rename folder: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename folder"})
+		return
+	}
+
+	h.invalidateFolderCaches(c.Request.Context(), folder.UserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"folder": folder,
+	})
+}
+
+func (h *Handler) MoveFolder(c *gin.Context) {
+	folder := authorizedFolder(c)
+
+	var request MoveRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	parentID, err := h.scopedFolderID(c, folder.UserID, request.FolderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Destination folder not found"})
+		return
+	}
+
+	if parentID != nil {
+		descendants, err := h.descendantFolderIDs(folder.UserID, folder.ID)
+		if err != nil {
+			log.Printf("Failed to retrieve folders: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move folder"})
+			return
+		}
+		for _, descendant := range descendants {
+			if descendant == *parentID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot move a folder into itself"})
+				return
+			}
+		}
+	}
+	if h.folderNameTaken(folder.UserID, parentID, folder.Name, folder.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Folder already exists"})
+		return
+	}
+
+	folder.ParentID = parentID
+	if result := h.db.Save(&folder); result.Error != nil {
+		log.Printf("Failed to move folder: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move folder"})
+		return
+	}
+
+	h.invalidateFolderCaches(c.Request.Context(), folder.UserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"folder": folder,
+	})
+}
+
+func (h *Handler) DeleteFolder(c *gin.Context) {
+	folder := authorizedFolder(c)
+
+	folderIDs, err := h.descendantFolderIDs(folder.UserID, folder.ID)
+	if err != nil {
+		log.Printf("Failed to retrieve folders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+
+	var files []models.FileMetadata
+	if result := h.db.Where("user_id = ? AND folder_id IN ?", folder.UserID, folderIDs).Find(&files); result.Error != nil {
+		log.Printf("Failed to retrieve files: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+
+	// Deleting anything but an empty folder has to be asked for explicitly
+	if len(folderIDs) > 1 || len(files) > 0 {
+		if c.Query("recursive") != "true" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Folder is not empty"})
+			return
+		}
+		if c.Query("confirm") != folder.Name {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Confirm the recursive delete by passing the folder name as confirm"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	for _, file := range files {
+		if err := h.trashFile(ctx, &file); err != nil {
+			log.Printf("Failed to delete file %s: %v", file.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder contents"})
+			return
+		}
+	}
+
+	if result := h.db.Where("user_id = ? AND id IN ?", folder.UserID, folderIDs).Delete(&models.Folder{}); result.Error != nil {
+		log.Printf("Failed to delete folders: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+
+	if result := h.db.Where("resource_type = ? AND resource_id IN ?", models.ResourceFolder, folderIDs).Delete(&models.Permission{}); result.Error != nil {
+		log.Printf("Failed to delete folder permissions: %v", result.Error)
+	}
+
+	h.invalidateFolderCaches(ctx, folder.UserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Folder deleted successfully",
+		"deleted_folders": len(folderIDs),
+		"trashed_files":   len(files),
+	})
+}
+
+func (h *Handler) MoveFile(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+
+	var request MoveRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	folderID, err := h.scopedFolderID(c, fileMetadata.UserID, request.FolderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Destination folder not found"})
+		return
+	}
+	if message, exists := h.fileNameConflict(fileMetadata.UserID, folderID, fileMetadata.FileName, fileMetadata.ID); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": message})
+		return
+	}
+
+	fileMetadata.FolderID = folderID
+	if result := h.db.Save(&fileMetadata); result.Error != nil {
+		log.Printf("Failed to move file: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move file"})
+		return
+	}
+
+	h.invalidateFileCaches(c.Request.Context(), fileMetadata.UserID, fileMetadata.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "File moved successfully",
+		"file_id":   fileMetadata.ID,
+		"folder_id": fileMetadata.FolderID,
+	})
+}
+
+// resolveFolderID turns a folder ID from a request into a folder owned by
+// the user. An empty string means the root and resolves to nil.
+func (h *Handler) resolveFolderID(userID uuid.UUID, rawID string) (*uuid.UUID, error) {
+	if rawID == "" {
+		return nil, nil
+	}
+	folderUUID, err := uuid.Parse(rawID)
+	if err != nil {
+		return nil, errFolderNotFound
+	}
+
+	var folder models.Folder
+	result := h.db.Where("id = ? AND user_id = ?", folderUUID, userID).First(&folder)
+	if result.Error != nil {
+		return nil, errFolderNotFound
+	}
+	return &folder.ID, nil
+}
+
+// scopedFolderID is resolveFolderID for requests that may come with a
+// folder API key. Such a key works inside its folder: the root means the
+// key's folder, and folders outside it are not found.
+func (h *Handler) scopedFolderID(c *gin.Context, userID uuid.UUID, rawID string) (*uuid.UUID, error) {
+	apiKey, ok := currentAPIKey(c)
+	if !ok || apiKey.FolderID == nil {
+		return h.resolveFolderID(userID, rawID)
+	}
+	if rawID == "" {
+		rawID = apiKey.FolderID.String()
+	}
+	folderID, err := h.resolveFolderID(userID, rawID)
+	if err != nil {
+		return nil, err
+	}
+	inside, err := authz.InFolder(h.db, folderID, *apiKey.FolderID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking API key folder: %v", err)
+	}
+	if !inside {
+		return nil, errFolderNotFound
+	}
+	return folderID, nil
+}
+
+// whereFolder matches rows whose column points at the given folder, or at
+// the root when folderID is nil.
+func whereFolder(query *gorm.DB, column string, folderID *uuid.UUID) *gorm.DB {
+	if folderID == nil {
+		return query.Where(column + " IS NULL")
+	}
+	return query.Where(column+" = ?", *folderID)
+}
+
+func (h *Handler) childFolders(userID uuid.UUID, parentID *uuid.UUID) ([]models.Folder, error) {
+	var folders []models.Folder
+	result := whereFolder(h.db.Where("user_id = ?", userID), "parent_id", parentID).Order("name").Find(&folders)
+	return folders, result.Error
+}
+
+func (h *Handler) folderNameTaken(userID uuid.UUID, parentID *uuid.UUID, name string, excludeID uuid.UUID) bool {
+	var count int64
+	whereFolder(h.db.Model(&models.Folder{}).Where("user_id = ? AND name = ? AND id <> ?", userID, name, excludeID), "parent_id", parentID).Count(&count)
+	return count > 0
+}
+
+// descendantFolderIDs returns the folder and every folder below it.
+func (h *Handler) descendantFolderIDs(userID uuid.UUID, folderID uuid.UUID) ([]uuid.UUID, error) {
+	folderIDs := []uuid.UUID{folderID}
+	level := []uuid.UUID{folderID}
+	for depth := 0; len(level) > 0 && depth < maxFolderDepth; depth++ {
+		var children []uuid.UUID
+		result := h.db.Model(&models.Folder{}).Where("user_id = ? AND parent_id IN ?", userID, level).Pluck("id", &children)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		folderIDs = append(folderIDs, children...)
+		level = children
+	}
+	return folderIDs, nil
+}
+
+// folderBreadcrumbs returns the path from the root down to the folder.
+func (h *Handler) folderBreadcrumbs(folder models.Folder) []gin.H {
+	path := []models.Folder{folder}
+	for depth := 0; folder.ParentID != nil && depth < maxFolderDepth; depth++ {
+		var parent models.Folder
+		if result := h.db.Where("id = ? AND user_id = ?", *folder.ParentID, folder.UserID).First(&parent); result.Error != nil {
+			break
+		}
+		path = append([]models.Folder{parent}, path...)
+		folder = parent
+	}
+
+	breadcrumbs := make([]gin.H, 0, len(path))
+	for _, crumb := range path {
+		breadcrumbs = append(breadcrumbs, gin.H{"id": crumb.ID, "name": crumb.Name})
+	}
+	return breadcrumbs
+}
+
+func validFolderName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") || len(name) > 255 {
+		return "", false
+	}
+	return name, true
+}
+
+// invalidateFolderCaches drops the cached directory listings of the user.
+func (h *Handler) invalidateFolderCaches(ctx context.Context, userID uuid.UUID) {
+	cacheKey := "files:" + userID.String()
+	err := h.cache.Del(ctx, cacheKey).Err()
+	if err != nil {
+		log.Printf("Failed to delete cache entry: %v", err)
+	}
+}
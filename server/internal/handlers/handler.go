@@ -0,0 +1,49 @@
+// internal/handlers/handler.go
+package handlers
+
+import (
+	"strings"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/blobs"
+	"github.com/ayushh2k/go-store-s3/server/internal/config"
+	"github.com/ayushh2k/go-store-s3/server/internal/oidc"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
+	"github.com/ayushh2k/go-store-s3/server/internal/workers"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Handler serves the file API. Its methods are the Gin handlers, and it
+// holds everything they use, so a process can run more than one.
+type Handler struct {
+	config config.Config
+	// baseURL is where the API is reachable from outside, including any
+	// prefix it is mounted under
+	baseURL string
+	db      *gorm.DB
+	cache   *redis.Client
+	storage storage.Backend
+	blobs   *blobs.Manager
+	outbox  *outbox.Outbox
+	workers *workers.Workers
+	tokens  *tokens.Manager
+	// oidc is nil unless OIDC login is configured
+	oidc *oidc.Provider
+}
+
+func New(cfg config.Config, db *gorm.DB, cache *redis.Client, store storage.Backend, blobManager *blobs.Manager, box *outbox.Outbox, jobs *workers.Workers, tokenManager *tokens.Manager, oidcProvider *oidc.Provider) *Handler {
+	return &Handler{
+		config:  cfg,
+		baseURL: strings.TrimRight(cfg.Server.PublicURL, "/") + cfg.Server.Prefix,
+		db:      db,
+		cache:   cache,
+		storage: store,
+		blobs:   blobManager,
+		outbox:  box,
+		workers: jobs,
+		tokens:  tokenManager,
+		oidc:    oidcProvider,
+	}
+}
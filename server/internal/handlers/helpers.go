@@ -0,0 +1,90 @@
+// internal/handlers/helpers.go
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// currentUser returns the authenticated user set by AuthMiddleware. If the
+// user is missing it writes the error response and returns false.
+func currentUser(c *gin.Context) (models.User, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return models.User{}, false
+	}
+	userObj, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user object"})
+		return models.User{}, false
+	}
+	return userObj, true
+}
+
+// currentClaims returns the access token claims set by AuthMiddleware.
+func currentClaims(c *gin.Context) tokens.Claims {
+	return c.MustGet("claims").(tokens.Claims)
+}
+
+// currentAPIKey returns the API key the request was made with, if any.
+func currentAPIKey(c *gin.Context) (models.APIKey, bool) {
+	apiKey, exists := c.Get("api_key")
+	if !exists {
+		return models.APIKey{}, false
+	}
+	return apiKey.(models.APIKey), true
+}
+
+// authorizedFile returns the file loaded and checked by
+// middleware.AuthorizeFile.
+func authorizedFile(c *gin.Context) models.FileMetadata {
+	return c.MustGet("file").(models.FileMetadata)
+}
+
+// authorizedFolder returns the folder loaded and checked by
+// middleware.AuthorizeFolder.
+func authorizedFolder(c *gin.Context) models.Folder {
+	return c.MustGet("folder").(models.Folder)
+}
+
+// invalidateFileCaches drops every cache entry that may contain the file:
+// the user's file listing, the shared link and the user's search results.
+func (h *Handler) invalidateFileCaches(ctx context.Context, userID uuid.UUID, fileID uuid.UUID) {
+	cacheKey := "files:" + userID.String()
+	err := h.cache.Del(ctx, cacheKey).Err()
+	if err != nil {
+		log.Printf("Failed to delete cache entry: %v", err)
+	}
+
+	sharedLinkCacheKey := "shared_link:" + fileID.String()
+	err = h.cache.Del(ctx, sharedLinkCacheKey).Err()
+	if err != nil {
+		log.Printf("Failed to delete shared link cache entry: %v", err)
+	}
+
+	h.invalidateCache(ctx, userID)
+}
+
+// fileNameConflict reports whether the folder already holds a file of the
+// user's named objectName, including files in the trash that could no longer
+// be restored. Names are unique per folder; excludeID skips the file itself
+// when it is being renamed or moved.
+func (h *Handler) fileNameConflict(userID uuid.UUID, folderID *uuid.UUID, objectName string, excludeID uuid.UUID) (string, bool) {
+	var existingFile models.FileMetadata
+	query := h.db.Unscoped().Where("file_name = ? AND user_id = ? AND id <> ?", objectName, userID, excludeID)
+	result := whereFolder(query, "folder_id", folderID).First(&existingFile)
+	if result.Error != nil {
+		return "", false
+	}
+	if existingFile.DeletedAt.Valid {
+		return "A file with the same name is in the trash", true
+	}
+	return "File already exists", true
+}
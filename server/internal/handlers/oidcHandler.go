@@ -0,0 +1,342 @@
+// internal/handlers/oidcHandler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// How long the user has to log in at the provider
+	oidcLoginTTL = 10 * time.Minute
+	// oidcStateCookie ties the callback to the browser that started the
+	// login, so nobody can log a victim into the attacker's account
+	oidcStateCookie = "oidc_state"
+)
+
+var (
+	errOIDCEmailUnverified = errors.New("email not verified by the identity provider")
+	errOIDCLinkRequired    = errors.New("account has a password, log in with it to link")
+)
+
+// oidcLogin is what is kept in the cache between OIDCLogin and the
+// callback.
+type oidcLogin struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// oidcLink is a provider account waiting to be linked to the password
+// account with its email, kept between the callback and OIDCLink.
+type oidcLink struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Issuer  string    `json:"issuer"`
+	Subject string    `json:"subject"`
+	Email   string    `json:"email"`
+}
+
+// OIDCLogin sends the browser to the identity provider to log in.
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	request, err := h.oidc.AuthCodeURL(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to start OIDC login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach the identity provider"})
+		return
+	}
+
+	login, err := json.Marshal(oidcLogin{Nonce: request.Nonce, Verifier: request.Verifier})
+	if err != nil {
+		log.Printf("Failed to marshal OIDC login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	err = h.cache.Set(c.Request.Context(), oidcLoginKey(request.State), login, oidcLoginTTL).Err()
+	if err != nil {
+		log.Printf("Failed to save OIDC login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	h.setOIDCStateCookie(c, request.State, int(oidcLoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, request.URL)
+}
+
+// OIDCCallback finishes a login at the identity provider. The user is
+// found by their provider account, or else by verified email, or else
+// created, and gets a session like a password login. A provider account
+// whose email belongs to a password account is only linked once the
+// password is given to OIDCLink.
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	h.setOIDCStateCookie(c, "", -1)
+	if state == "" || cookie != state {
+		h.oidcFail(c, http.StatusBadRequest, "Invalid login state, please try again")
+		return
+	}
+
+	raw, err := h.cache.GetDel(ctx, oidcLoginKey(state)).Result()
+	if errors.Is(err, redis.Nil) {
+		h.oidcFail(c, http.StatusBadRequest, "Login expired, please try again")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load OIDC login: %v", err)
+		h.oidcFail(c, http.StatusInternalServerError, "Failed to finish login")
+		return
+	}
+	var login oidcLogin
+	if err := json.Unmarshal([]byte(raw), &login); err != nil {
+		log.Printf("Failed to unmarshal OIDC login: %v", err)
+		h.oidcFail(c, http.StatusInternalServerError, "Failed to finish login")
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		h.oidcFail(c, http.StatusUnauthorized, "Login was denied by the identity provider: "+providerError)
+		return
+	}
+
+	identity, err := h.oidc.Exchange(ctx, c.Query("code"), login.Verifier, login.Nonce)
+	if errors.Is(err, oidc.ErrExchangeFailed) || errors.Is(err, oidc.ErrInvalidIDToken) {
+		log.Printf("Failed OIDC login: %v", err)
+		h.oidcFail(c, http.StatusUnauthorized, "Login with the identity provider failed")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to finish OIDC login: %v", err)
+		h.oidcFail(c, http.StatusBadGateway, "Failed to reach the identity provider")
+		return
+	}
+
+	user, err := h.oidcUser(identity)
+	if errors.Is(err, errOIDCEmailUnverified) {
+		h.oidcFail(c, http.StatusForbidden, "Your identity provider hasn't verified your email")
+		return
+	}
+	if errors.Is(err, errOIDCLinkRequired) {
+		h.oidcRequireLink(c, oidcLink{
+			UserID:  user.ID,
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+			Email:   identity.Email,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to find user for OIDC login: %v", err)
+		h.oidcFail(c, http.StatusInternalServerError, "Failed to finish login")
+		return
+	}
+
+	pair, err := h.tokens.Issue(user.ID, tokenClient(c))
+	if err != nil {
+		log.Printf("Failed to issue tokens: %v", err)
+		h.oidcFail(c, http.StatusInternalServerError, "Failed to sign token")
+		return
+	}
+
+	if h.config.Auth.OIDC.ClientURL == "" {
+		c.JSON(http.StatusOK, tokenResponse(pair))
+		return
+	}
+	// The fragment never reaches a server, so the tokens stay out of logs
+	fragment := url.Values{
+		"access_token":  {pair.AccessToken},
+		"refresh_token": {pair.RefreshToken},
+		"token_type":    {"Bearer"},
+		"expires_in":    {strconv.FormatInt(int64(pair.ExpiresIn.Seconds()), 10)},
+	}
+	c.Redirect(http.StatusFound, h.config.Auth.OIDC.ClientURL+"#"+fragment.Encode())
+}
+
+// oidcUser returns the user behind a provider account. An account seen for
+// the first time is linked to the user with its email, if the provider
+// has verified it, and a user is created when there is none. Created users
+// have no password, so they can only log in through the provider. Nothing
+// proves that the owner of a password account controls its email, so such a
+// user is returned with errOIDCLinkRequired instead of being linked.
+func (h *Handler) oidcUser(identity oidc.Identity) (models.User, error) {
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		result := tx.Where("issuer = ? AND subject = ?", identity.Issuer, identity.Subject).First(&link)
+		if result.Error == nil {
+			return tx.First(&user, "id = ?", link.UserID).Error
+		}
+		if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return result.Error
+		}
+
+		if identity.Email == "" || !identity.EmailVerified {
+			return errOIDCEmailUnverified
+		}
+		result = tx.Where("LOWER(email) = ?", strings.ToLower(identity.Email)).First(&user)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			user = models.User{Email: identity.Email}
+			result = tx.Create(&user)
+			if result.Error == nil {
+				log.Printf("Created user %s for OIDC subject %s", user.ID, identity.Subject)
+			}
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if user.Password != "" {
+			return errOIDCLinkRequired
+		}
+
+		link = models.UserIdentity{
+			UserID:  user.ID,
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+			Email:   identity.Email,
+		}
+		if result := tx.Create(&link); result.Error != nil {
+			return result.Error
+		}
+		log.Printf("Linked OIDC subject %s to user %s", identity.Subject, user.ID)
+		return nil
+	})
+	return user, err
+}
+
+// OIDCLink links a provider account to the password account with its email
+// once the account's password is given, and starts a session. Each link
+// token allows one attempt.
+func (h *Handler) OIDCLink(c *gin.Context) {
+	var body struct {
+		LinkToken string `json:"link_token" binding:"required"`
+		Password  string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	raw, err := h.cache.GetDel(ctx, oidcLinkKey(body.LinkToken)).Result()
+	if errors.Is(err, redis.Nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Link expired, please log in with the identity provider again"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load OIDC link: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link account"})
+		return
+	}
+	var link oidcLink
+	if err := json.Unmarshal([]byte(raw), &link); err != nil {
+		log.Printf("Failed to unmarshal OIDC link: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link account"})
+		return
+	}
+
+	var user models.User
+	if result := h.db.First(&user, "id = ?", link.UserID); result.Error != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	identity := models.UserIdentity{
+		UserID:  user.ID,
+		Issuer:  link.Issuer,
+		Subject: link.Subject,
+		Email:   link.Email,
+	}
+	if result := h.db.Create(&identity); result.Error != nil {
+		log.Printf("Failed to link OIDC subject: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link account"})
+		return
+	}
+	log.Printf("Linked OIDC subject %s to user %s after a password login", link.Subject, user.ID)
+
+	pair, err := h.tokens.Issue(user.ID, tokenClient(c))
+	if err != nil {
+		log.Printf("Failed to issue tokens: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse(pair))
+}
+
+// oidcRequireLink ends a login whose provider account has to be linked with
+// the password first. The client gets a token to send to OIDCLink with the
+// password.
+func (h *Handler) oidcRequireLink(c *gin.Context, link oidcLink) {
+	pending, err := json.Marshal(link)
+	if err != nil {
+		log.Printf("Failed to marshal OIDC link: %v", err)
+		h.oidcFail(c, http.StatusInternalServerError, "Failed to finish login")
+		return
+	}
+	token := uuid.New().String()
+	err = h.cache.Set(c.Request.Context(), oidcLinkKey(token), pending, oidcLoginTTL).Err()
+	if err != nil {
+		log.Printf("Failed to save OIDC link: %v", err)
+		h.oidcFail(c, http.StatusInternalServerError, "Failed to finish login")
+		return
+	}
+
+	message := "An account with this email already exists, log in with its password to link it"
+	if h.config.Auth.OIDC.ClientURL == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": message, "link_token": token})
+		return
+	}
+	fragment := url.Values{"error": {message}, "link_token": {token}}
+	c.Redirect(http.StatusFound, h.config.Auth.OIDC.ClientURL+"#"+fragment.Encode())
+}
+
+// oidcFail ends a login with an error. Browsers are sent back to the
+// client with the error in the URL fragment.
+func (h *Handler) oidcFail(c *gin.Context, status int, message string) {
+	if h.config.Auth.OIDC.ClientURL == "" {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	fragment := url.Values{"error": {message}}
+	c.Redirect(http.StatusFound, h.config.Auth.OIDC.ClientURL+"#"+fragment.Encode())
+}
+
+func (h *Handler) setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := strings.HasPrefix(h.config.Auth.OIDC.RedirectURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, h.config.Server.Prefix+"/auth/oidc", "", secure, true)
+}
+
+func oidcLoginKey(state string) string {
+	return "oidc_login:" + state
+}
+
+func oidcLinkKey(token string) string {
+	return "oidc_link:" + token
+}
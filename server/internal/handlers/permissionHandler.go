@@ -0,0 +1,258 @@
+// internal/handlers/permissionHandler.go
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type GrantPermissionRequest struct {
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role" binding:"required"`
+}
+
+func (h *Handler) GrantFilePermission(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	fileMetadata := authorizedFile(c)
+	h.grantPermission(c, userObj.ID, models.ResourceFile, fileMetadata.ID, fileMetadata.UserID)
+}
+
+func (h *Handler) ListFilePermissions(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+	h.listPermissions(c, models.ResourceFile, fileMetadata.ID)
+}
+
+func (h *Handler) RevokeFilePermission(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+	h.revokePermission(c, models.ResourceFile, fileMetadata.ID)
+}
+
+func (h *Handler) GrantFolderPermission(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	folder := authorizedFolder(c)
+	h.grantPermission(c, userObj.ID, models.ResourceFolder, folder.ID, folder.UserID)
+}
+
+func (h *Handler) ListFolderPermissions(c *gin.Context) {
+	folder := authorizedFolder(c)
+	h.listPermissions(c, models.ResourceFolder, folder.ID)
+}
+
+func (h *Handler) RevokeFolderPermission(c *gin.Context) {
+	folder := authorizedFolder(c)
+	h.revokePermission(c, models.ResourceFolder, folder.ID)
+}
+
+// SharedWithMe lists the files and folders other users have granted the
+// user a role on.
+func (h *Handler) SharedWithMe(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var permissions []models.Permission
+	if result := h.db.Where("user_id = ?", userObj.ID).Find(&permissions); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shared items"})
+		return
+	}
+
+	fileRoles := map[uuid.UUID]string{}
+	folderRoles := map[uuid.UUID]string{}
+	for _, permission := range permissions {
+		if permission.ResourceType == models.ResourceFile {
+			fileRoles[permission.ResourceID] = permission.Role
+		} else {
+			folderRoles[permission.ResourceID] = permission.Role
+		}
+	}
+
+	var files []models.FileMetadata
+	if len(fileRoles) > 0 {
+		if result := h.db.Where("id IN ?", mapKeys(fileRoles)).Order("file_name").Find(&files); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shared items"})
+			return
+		}
+	}
+	var folders []models.Folder
+	if len(folderRoles) > 0 {
+		if result := h.db.Where("id IN ?", mapKeys(folderRoles)).Order("name").Find(&folders); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shared items"})
+			return
+		}
+	}
+
+	sharedFiles := make([]gin.H, 0, len(files))
+	for _, file := range files {
+		sharedFiles = append(sharedFiles, gin.H{"file": file, "role": fileRoles[file.ID]})
+	}
+	sharedFolders := make([]gin.H, 0, len(folders))
+	for _, folder := range folders {
+		sharedFolders = append(sharedFolders, gin.H{"folder": folder, "role": folderRoles[folder.ID]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files":   sharedFiles,
+		"folders": sharedFolders,
+	})
+}
+
+// ListSharedFolder lists one level of a folder shared with the user.
+func (h *Handler) ListSharedFolder(c *gin.Context) {
+	folder := authorizedFolder(c)
+
+	folders, err := h.childFolders(folder.UserID, &folder.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve folders"})
+		return
+	}
+
+	var files []models.FileMetadata
+	result := h.db.Where("user_id = ? AND folder_id = ?", folder.UserID, folder.ID).Find(&files)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve files"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"folder":  folder,
+		"folders": folders,
+		"files":   files,
+	})
+}
+
+// grantPermission gives the user with the requested email a role on the
+// resource, replacing any role they already had.
+func (h *Handler) grantPermission(c *gin.Context, grantedBy uuid.UUID, resourceType string, resourceID uuid.UUID, ownerID uuid.UUID) {
+	var request GrantPermissionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if models.RoleRank(request.Role) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be viewer, editor or owner"})
+		return
+	}
+
+	var grantee models.User
+	result := h.db.Where("email = ?", strings.TrimSpace(request.Email)).First(&grantee)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if grantee.ID == ownerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner already has full access"})
+		return
+	}
+
+	var permission models.Permission
+	result = h.db.Where("user_id = ? AND resource_type = ? AND resource_id = ?", grantee.ID, resourceType, resourceID).First(&permission)
+	if result.Error == nil {
+		permission.Role = request.Role
+		permission.GrantedBy = grantedBy
+		result = h.db.Save(&permission)
+	} else {
+		permission = models.Permission{
+			UserID:       grantee.ID,
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+			Role:         request.Role,
+			GrantedBy:    grantedBy,
+		}
+		result = h.db.Create(&permission)
+	}
+	if result.Error != nil {
+		log.Printf("Failed to save permission: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant permission"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissionResponse(permission, grantee.Email))
+}
+
+func (h *Handler) listPermissions(c *gin.Context, resourceType string, resourceID uuid.UUID) {
+	var permissions []models.Permission
+	result := h.db.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).Order("created_at").Find(&permissions)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve permissions"})
+		return
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(permissions))
+	for _, permission := range permissions {
+		userIDs = append(userIDs, permission.UserID)
+	}
+	emails := map[uuid.UUID]string{}
+	if len(userIDs) > 0 {
+		var users []models.User
+		if result := h.db.Select("id", "email").Where("id IN ?", userIDs).Find(&users); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve permissions"})
+			return
+		}
+		for _, user := range users {
+			emails[user.ID] = user.Email
+		}
+	}
+
+	response := make([]gin.H, 0, len(permissions))
+	for _, permission := range permissions {
+		response = append(response, permissionResponse(permission, emails[permission.UserID]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"permissions": response,
+	})
+}
+
+func (h *Handler) revokePermission(c *gin.Context, resourceType string, resourceID uuid.UUID) {
+	permissionUUID, err := uuid.Parse(c.Param("permission_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission ID"})
+		return
+	}
+
+	result := h.db.Where("id = ? AND resource_type = ? AND resource_id = ?", permissionUUID, resourceType, resourceID).Delete(&models.Permission{})
+	if result.Error != nil {
+		log.Printf("Failed to delete permission: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke permission"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permission not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Permission revoked",
+	})
+}
+
+func permissionResponse(permission models.Permission, email string) gin.H {
+	return gin.H{
+		"permission_id": permission.ID,
+		"user_id":       permission.UserID,
+		"email":         email,
+		"role":          permission.Role,
+		"granted_by":    permission.GrantedBy,
+		"created_at":    permission.CreatedAt,
+	}
+}
+
+func mapKeys(m map[uuid.UUID]string) []uuid.UUID {
+	keys := make([]uuid.UUID, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
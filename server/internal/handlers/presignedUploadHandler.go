@@ -0,0 +1,320 @@
+// internal/handlers/presignedUploadHandler.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	maxDirectUploadSize = 5 * 1024 * 1024 * 1024 // 5GB, the S3 limit for a single PUT
+	presignedUploadTTL  = 15 * time.Minute
+)
+
+type PresignUploadRequest struct {
+	FileName    string `json:"file_name" binding:"required"`
+	FileSize    int64  `json:"file_size" binding:"min=0"`
+	ContentType string `json:"content_type"`
+	Method      string `json:"method"`
+	FolderID    string `json:"folder_id"`
+}
+
+type ConfirmUploadRequest struct {
+	UploadID string `json:"upload_id" binding:"required"`
+}
+
+// presignedUpload is what the server remembers about a handed out policy
+// until the client confirms the upload. The client uploads to ObjectName, a
+// key of the upload's own, so it can't overwrite any other object.
+type presignedUpload struct {
+	UserID      uuid.UUID  `json:"user_id"`
+	FolderID    *uuid.UUID `json:"folder_id"`
+	FileName    string     `json:"file_name"`
+	ObjectName  string     `json:"object_name"`
+	FileSize    int64      `json:"file_size"`
+	ContentType string     `json:"content_type"`
+}
+
+func (h *Handler) PresignUpload(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var request PresignUploadRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if request.Method == "" {
+		request.Method = "post"
+	}
+	if request.Method != "post" && request.Method != "put" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Method must be post or put"})
+		return
+	}
+	if request.FileSize > maxDirectUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large, use an upload session instead"})
+		return
+	}
+
+	fileName := path.Base(request.FileName)
+	if fileName == "." || fileName == "/" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file name"})
+		return
+	}
+	contentType := request.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	folderID, err := h.scopedFolderID(c, userObj.ID, request.FolderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	objectName := fmt.Sprintf("%s/%s", userObj.ID.String(), fileName)
+
+	if message, exists := h.fileNameConflict(userObj.ID, folderID, objectName, uuid.Nil); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": message})
+		return
+	}
+
+	if !h.checkQuota(c, userObj.ID, request.FileSize) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.storage.EnsureBucket(ctx); err != nil {
+		log.Printf("Failed to ensure bucket exists: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ensure bucket exists"})
+		return
+	}
+
+	uploadID := uuid.New()
+	uploadObjectName := ".uploads/" + uploadID.String()
+	expiresAt := time.Now().Add(presignedUploadTTL)
+	response := gin.H{
+		"method":     request.Method,
+		"filename":   objectName,
+		"expires_at": expiresAt,
+	}
+
+	if request.Method == "post" {
+		presignedURL, formData, err := h.storage.PresignPost(ctx, uploadObjectName, presignedUploadTTL, contentType, request.FileSize)
+		if errors.Is(err, storage.ErrNotSupported) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Pre-signed uploads are not supported by the storage backend"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to generate post policy: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate upload policy"})
+			return
+		}
+		response["url"] = presignedURL
+		response["fields"] = formData
+	} else {
+		// Signing the headers makes S3 reject a PUT with a different size or type
+		headers := http.Header{}
+		headers.Set("Content-Type", contentType)
+		headers.Set("Content-Length", strconv.FormatInt(request.FileSize, 10))
+
+		presignedURL, err := h.storage.PresignPut(ctx, uploadObjectName, presignedUploadTTL, headers)
+		if errors.Is(err, storage.ErrNotSupported) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Pre-signed uploads are not supported by the storage backend"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to generate pre-signed URL: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pre-signed URL"})
+			return
+		}
+		response["url"] = presignedURL
+		response["headers"] = gin.H{
+			"Content-Type":   contentType,
+			"Content-Length": strconv.FormatInt(request.FileSize, 10),
+		}
+	}
+
+	pending, err := json.Marshal(presignedUpload{
+		UserID:      userObj.ID,
+		FolderID:    folderID,
+		FileName:    objectName,
+		ObjectName:  uploadObjectName,
+		FileSize:    request.FileSize,
+		ContentType: contentType,
+	})
+	if err != nil {
+		log.Printf("Failed to marshal presigned upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate upload policy"})
+		return
+	}
+
+	// Keep the record a while past the policy expiry so a slow upload can still be confirmed
+	err = h.cache.Set(ctx, "presigned_upload:"+uploadID.String(), pending, 2*presignedUploadTTL).Err()
+	if err != nil {
+		log.Printf("Failed to save presigned upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate upload policy"})
+		return
+	}
+
+	response["upload_id"] = uploadID
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ConfirmUpload(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var request ConfirmUploadRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	uploadID, err := uuid.Parse(request.UploadID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	pendingKey := "presigned_upload:" + uploadID.String()
+	// Claim the record before the checks, so concurrent confirms of the same
+	// upload can't both pass them. It is put back when the confirm fails in
+	// a way the client may retry.
+	var remaining *redis.DurationCmd
+	var claimed *redis.StringCmd
+	h.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		remaining = pipe.PTTL(ctx, pendingKey)
+		claimed = pipe.GetDel(ctx, pendingKey)
+		return nil
+	})
+	cached, err := claimed.Result()
+	if err == redis.Nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found or expired"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to load presigned upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm upload"})
+		return
+	}
+
+	keepPending := true
+	defer func() {
+		if keepPending && remaining.Val() > 0 {
+			if err := h.cache.Set(context.Background(), pendingKey, cached, remaining.Val()).Err(); err != nil {
+				log.Printf("Failed to restore presigned upload: %v", err)
+			}
+		}
+	}()
+
+	var pending presignedUpload
+	if err := json.Unmarshal([]byte(cached), &pending); err != nil || pending.UserID != userObj.ID || pending.ObjectName == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found or expired"})
+		return
+	}
+
+	objectInfo, err := h.storage.Stat(ctx, pending.ObjectName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File has not been uploaded yet"})
+			return
+		}
+		log.Printf("Failed to stat uploaded object: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm upload"})
+		return
+	}
+	if objectInfo.Size != pending.FileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded file size does not match"})
+		return
+	}
+
+	if message, exists := h.fileNameConflict(userObj.ID, pending.FolderID, pending.FileName, uuid.Nil); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": message})
+		return
+	}
+
+	// The object is already stored; drop it if it no longer fits in the quota
+	releaseQuota, err := h.reserveQuotaBytes(ctx, userObj.ID, objectInfo.Size)
+	if err != nil {
+		var quotaErr *quotaExceededError
+		if errors.As(err, &quotaErr) {
+			if err := h.storage.Delete(ctx, pending.ObjectName); err != nil {
+				log.Printf("Failed to delete uploaded object: %v", err)
+			}
+			keepPending = false
+		}
+		respondQuotaError(c, err)
+		return
+	}
+	defer releaseQuota()
+
+	// The client uploaded straight to storage, so the checksums are computed
+	// by reading the object back
+	hasher := newChecksumHasher()
+	if _, err := h.hashObject(ctx, pending.ObjectName, hasher); err != nil {
+		log.Printf("Failed to hash uploaded object: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm upload"})
+		return
+	}
+	sums := hasher.Sum()
+
+	// The uploaded object is stored once per hash by the blobs package
+	var fileMetadata models.FileMetadata
+	blobObjectName, err := h.blobs.Store(ctx, pending.ObjectName, sums.SHA256, objectInfo.Size, func(tx *gorm.DB, blobObjectName string) error {
+		fileMetadata = models.FileMetadata{
+			FileName:       pending.FileName,
+			FileURL:        h.storage.URL(blobObjectName),
+			FileSize:       objectInfo.Size,
+			ContentType:    pending.ContentType,
+			UploadedAt:     time.Now(),
+			UserID:         userObj.ID,
+			FolderID:       pending.FolderID,
+			ObjectName:     blobObjectName,
+			ContentHash:    sums.SHA256,
+			ChecksumSHA256: sums.SHA256,
+			ChecksumCRC32C: sums.CRC32C,
+		}
+		if result := tx.Create(&fileMetadata); result.Error != nil {
+			return fmt.Errorf("error saving file metadata: %v", result.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to store file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file metadata"})
+		return
+	}
+	fileURL := h.storage.URL(blobObjectName)
+
+	keepPending = false
+	h.invalidateFileCaches(context.Background(), userObj.ID, fileMetadata.ID)
+	h.notifyQuotaThresholds(context.Background(), userObj.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "File uploaded successfully",
+		"file_id":         fileMetadata.ID,
+		"filename":        fileMetadata.FileName,
+		"file_size":       fileMetadata.FileSize,
+		"checksum_sha256": sums.SHA256,
+		"checksum_crc32c": sums.CRC32C,
+		"upload_url":      fileURL,
+	})
+}
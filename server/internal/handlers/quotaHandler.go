@@ -0,0 +1,258 @@
+// internal/handlers/quotaHandler.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// Reservations of crashed uploads disappear after this long
+	quotaReservationTTL = time.Hour
+)
+
+// Usage warnings in percent of the quota, highest first
+var quotaWarningThresholds = []int{95, 80}
+
+// quotaExceededError is returned when an upload doesn't fit in the quota.
+type quotaExceededError struct {
+	Quota int64
+	Used  int64
+	Size  int64
+}
+
+func (e *quotaExceededError) Error() string {
+	return fmt.Sprintf("storage quota exceeded: %d of %d bytes used, %d more requested", e.Used, e.Quota, e.Size)
+}
+
+// storageUsage is what a user stores, in bytes.
+type storageUsage struct {
+	Files    int64
+	Trash    int64
+	Versions int64
+}
+
+func (usage storageUsage) Total() int64 {
+	return usage.Files + usage.Trash + usage.Versions
+}
+
+func (h *Handler) GetQuota(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	quota := h.userQuota(userObj)
+	usage, err := h.storageUsed(userObj.ID)
+	if err != nil {
+		log.Printf("Failed to retrieve storage used: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve quota"})
+		return
+	}
+	reserved, err := h.cache.Get(c.Request.Context(), quotaReservationKey(userObj.ID)).Int64()
+	if err != nil {
+		reserved = 0
+	}
+
+	remaining := quota - usage.Total()
+	if remaining < 0 {
+		remaining = 0
+	}
+	percentUsed := 0.0
+	if quota > 0 {
+		percentUsed = float64(usage.Total()) * 100 / float64(quota)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"quota":         quota,
+		"used":          usage.Total(),
+		"reserved":      reserved,
+		"remaining":     remaining,
+		"percent_used":  percentUsed,
+		"warning_level": quotaWarningLevel(usage.Total(), quota),
+	})
+}
+
+// userQuota returns the user's quota in bytes: their own override, or
+// the default quota.
+func (h *Handler) userQuota(user models.User) int64 {
+	if user.QuotaBytes != nil {
+		return *user.QuotaBytes
+	}
+	return h.config.Limits.DefaultQuotaMB * 1024 * 1024
+}
+
+// storageUsed adds up the user's files, the files in their trash and the
+// earlier versions of their files.
+func (h *Handler) storageUsed(userID uuid.UUID) (storageUsage, error) {
+	var usage storageUsage
+	err := h.db.Model(&models.FileMetadata{}).Where("user_id = ?", userID).Select("COALESCE(SUM(file_size), 0)").Row().Scan(&usage.Files)
+	if err != nil {
+		return storageUsage{}, err
+	}
+	err = h.db.Unscoped().Model(&models.FileMetadata{}).Where("user_id = ? AND deleted_at IS NOT NULL", userID).Select("COALESCE(SUM(file_size), 0)").Row().Scan(&usage.Trash)
+	if err != nil {
+		return storageUsage{}, err
+	}
+	err = h.db.Model(&models.FileVersion{}).Where("user_id = ?", userID).Select("COALESCE(SUM(file_size), 0)").Row().Scan(&usage.Versions)
+	if err != nil {
+		return storageUsage{}, err
+	}
+	return usage, nil
+}
+
+// reserveQuotaBytes holds size bytes of the user's quota until the returned
+// release func is called. Reservations of concurrent uploads add up in a
+// single Redis counter, so together they can't go past the quota. It
+// returns a *quotaExceededError when the bytes don't fit.
+//
+// The bytes are reserved before the usage is read, and callers release them
+// only once the file's row is saved. Every other upload is then counted at
+// least once: in the counter, or in the usage if it has already released.
+func (h *Handler) reserveQuotaBytes(ctx context.Context, userID uuid.UUID, size int64) (func(), error) {
+	key := quotaReservationKey(userID)
+	reserved, err := h.cache.IncrBy(ctx, key, size).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reserving quota: %v", err)
+	}
+	h.cache.Expire(ctx, key, quotaReservationTTL)
+
+	release := func() {
+		if err := h.cache.DecrBy(context.Background(), key, size).Err(); err != nil {
+			log.Printf("Failed to release quota reservation: %v", err)
+		}
+	}
+
+	var user models.User
+	if result := h.db.First(&user, "id = ?", userID); result.Error != nil {
+		release()
+		return nil, fmt.Errorf("error loading user: %v", result.Error)
+	}
+	quota := h.userQuota(user)
+	usage, err := h.storageUsed(userID)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("error retrieving storage used: %v", err)
+	}
+
+	if usage.Total()+reserved > quota {
+		release()
+		return nil, &quotaExceededError{Quota: quota, Used: usage.Total() + reserved - size, Size: size}
+	}
+	return release, nil
+}
+
+// reserveQuota is reserveQuotaBytes for handlers. It writes the error
+// response when it returns false.
+func (h *Handler) reserveQuota(c *gin.Context, userID uuid.UUID, size int64) (func(), bool) {
+	release, err := h.reserveQuotaBytes(c.Request.Context(), userID, size)
+	if err != nil {
+		respondQuotaError(c, err)
+		return nil, false
+	}
+	return release, true
+}
+
+// checkQuota reports whether size more bytes currently fit in the user's
+// quota. It writes the error response when it returns false.
+func (h *Handler) checkQuota(c *gin.Context, userID uuid.UUID, size int64) bool {
+	release, ok := h.reserveQuota(c, userID, size)
+	if ok {
+		release()
+	}
+	return ok
+}
+
+// respondQuotaError answers 413 when the upload is larger than the whole
+// quota and 507 when it is larger than the space left.
+func respondQuotaError(c *gin.Context, err error) {
+	var quotaErr *quotaExceededError
+	if !errors.As(err, &quotaErr) {
+		log.Printf("Failed to check quota: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+
+	remaining := quotaErr.Quota - quotaErr.Used
+	if remaining < 0 {
+		remaining = 0
+	}
+	status := http.StatusInsufficientStorage
+	message := "Not enough storage space left"
+	if quotaErr.Size > quotaErr.Quota {
+		status = http.StatusRequestEntityTooLarge
+		message = "File is larger than your storage quota"
+	}
+	c.JSON(status, gin.H{
+		"error":     message,
+		"quota":     quotaErr.Quota,
+		"used":      quotaErr.Used,
+		"remaining": remaining,
+	})
+}
+
+// notifyQuotaThresholds records a QuotaEvent when the user's usage passes a
+// warning threshold it was below before.
+func (h *Handler) notifyQuotaThresholds(ctx context.Context, userID uuid.UUID) {
+	var user models.User
+	if result := h.db.First(&user, "id = ?", userID); result.Error != nil {
+		log.Printf("Failed to load user: %v", result.Error)
+		return
+	}
+	quota := h.userQuota(user)
+	usage, err := h.storageUsed(userID)
+	if err != nil {
+		log.Printf("Failed to retrieve storage used: %v", err)
+		return
+	}
+
+	level := quotaWarningLevel(usage.Total(), quota)
+	levelKey := "quota_warning:" + userID.String()
+	previous, err := h.cache.Get(ctx, levelKey).Int()
+	if err != nil {
+		previous = 0
+	}
+	if level == previous {
+		return
+	}
+	if err := h.cache.Set(ctx, levelKey, level, 0).Err(); err != nil {
+		log.Printf("Failed to save quota warning level: %v", err)
+	}
+	if level < previous {
+		return
+	}
+
+	event := models.QuotaEvent{
+		UserID:     userID,
+		Threshold:  level,
+		UsedBytes:  usage.Total(),
+		QuotaBytes: quota,
+	}
+	if result := h.db.Create(&event); result.Error != nil {
+		log.Printf("Failed to save quota event: %v", result.Error)
+	}
+	log.Printf("User %s passed %d%% of their storage quota", userID, level)
+}
+
+// quotaWarningLevel returns the highest warning threshold the usage has
+// reached, or 0.
+func quotaWarningLevel(used, quota int64) int {
+	for _, threshold := range quotaWarningThresholds {
+		if used*100 >= quota*int64(threshold) {
+			return threshold
+		}
+	}
+	return 0
+}
+
+func quotaReservationKey(userID uuid.UUID) string {
+	return "quota_reserved:" + userID.String()
+}
@@ -4,24 +4,26 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/ayushh2k/go-store-s3/server/internal/initializers"
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 type SearchFilesRequest struct {
-	FileName    string `form:"file_name"`
-	UploadedAt  string `form:"uploaded_at"`
-	ContentType string `form:"content_type"`
+	FileName    string `form:"file_name" json:"file_name"`
+	UploadedAt  string `form:"uploaded_at" json:"uploaded_at"`
+	ContentType string `form:"content_type" json:"content_type"`
 }
 
-func SearchFiles(c *gin.Context) {
+func (h *Handler) SearchFiles(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -43,7 +45,7 @@ func SearchFiles(c *gin.Context) {
 	cacheKey := generateCacheKey(userObj.ID, searchRequest)
 
 	// Try to get the cached results
-	cachedFiles, err := getCachedSearchResults(c.Request.Context(), cacheKey)
+	cachedFiles, err := h.getCachedSearchResults(c.Request.Context(), cacheKey)
 	if err == nil {
 		c.JSON(http.StatusOK, gin.H{
 			"files": cachedFiles,
@@ -54,22 +56,10 @@ func SearchFiles(c *gin.Context) {
 	}
 
 	var files []models.FileMetadata
-	query := initializers.DB.Db.Where("user_id = ?", userObj.ID)
-
-	if searchRequest.FileName != "" {
-		query = query.Where("file_name ILIKE ?", "%"+searchRequest.FileName+"%")
-	}
-	if searchRequest.UploadedAt != "" {
-		// dd-mm-yyyy format
-		uploadedAt, err := time.Parse("02-01-2006", searchRequest.UploadedAt)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid uploaded_at format, expected dd-mm-yyyy"})
-			return
-		}
-		query = query.Where("uploaded_at::date = ?", uploadedAt.Format("2006-01-02"))
-	}
-	if searchRequest.ContentType != "" {
-		query = query.Where("content_type ILIKE ?", "%"+searchRequest.ContentType+"%")
+	query, err := h.buildSearchQuery(userObj.ID, searchRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	result := query.Find(&files)
@@ -80,7 +70,7 @@ func SearchFiles(c *gin.Context) {
 	}
 
 	// Cache the search results
-	err = cacheSearchResults(c.Request.Context(), cacheKey, files)
+	err = h.cacheSearchResults(c.Request.Context(), cacheKey, files)
 	if err != nil {
 		log.Printf("Failed to cache search results: %v", err)
 	}
@@ -90,12 +80,35 @@ func SearchFiles(c *gin.Context) {
 	})
 }
 
+// buildSearchQuery returns the query for the user's files matching the
+// search parameters.
+func (h *Handler) buildSearchQuery(userID uuid.UUID, searchRequest SearchFilesRequest) (*gorm.DB, error) {
+	query := h.db.Where("user_id = ?", userID)
+
+	if searchRequest.FileName != "" {
+		query = query.Where("LOWER(file_name) LIKE ?", "%"+strings.ToLower(searchRequest.FileName)+"%")
+	}
+	if searchRequest.UploadedAt != "" {
+		// dd-mm-yyyy format
+		uploadedAt, err := time.ParseInLocation("02-01-2006", searchRequest.UploadedAt, time.Local)
+		if err != nil {
+			return nil, errors.New("Invalid uploaded_at format, expected dd-mm-yyyy")
+		}
+		query = query.Where("uploaded_at >= ? AND uploaded_at < ?", uploadedAt, uploadedAt.AddDate(0, 0, 1))
+	}
+	if searchRequest.ContentType != "" {
+		query = query.Where("LOWER(content_type) LIKE ?", "%"+strings.ToLower(searchRequest.ContentType)+"%")
+	}
+
+	return query, nil
+}
+
 func generateCacheKey(userID uuid.UUID, searchRequest SearchFilesRequest) string {
 	return "search_results:" + userID.String() + ":" + searchRequest.FileName + ":" + searchRequest.UploadedAt + ":" + searchRequest.ContentType
 }
 
-func getCachedSearchResults(ctx context.Context, cacheKey string) ([]models.FileMetadata, error) {
-	cachedData, err := initializers.RedisClient.Get(ctx, cacheKey).Result()
+func (h *Handler) getCachedSearchResults(ctx context.Context, cacheKey string) ([]models.FileMetadata, error) {
+	cachedData, err := h.cache.Get(ctx, cacheKey).Result()
 	if err == redis.Nil {
 		return nil, err
 	} else if err != nil {
@@ -111,13 +124,13 @@ func getCachedSearchResults(ctx context.Context, cacheKey string) ([]models.File
 	return files, nil
 }
 
-func cacheSearchResults(ctx context.Context, cacheKey string, files []models.FileMetadata) error {
+func (h *Handler) cacheSearchResults(ctx context.Context, cacheKey string, files []models.FileMetadata) error {
 	jsonData, err := json.Marshal(files)
 	if err != nil {
 		return err
 	}
 
-	err = initializers.RedisClient.Set(ctx, cacheKey, jsonData, 1*time.Minute).Err()
+	err = h.cache.Set(ctx, cacheKey, jsonData, 1*time.Minute).Err()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,63 @@
+// internal/handlers/sessionHandler.go
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListSessions returns the devices the user is logged in on. The session
+// making the request is marked as current.
+func (h *Handler) ListSessions(c *gin.Context) {
+	claims := currentClaims(c)
+
+	sessions, err := h.tokens.Sessions(c.Request.Context(), claims.UserID)
+	if err != nil {
+		log.Printf("Failed to retrieve sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+
+	response := make([]gin.H, len(sessions))
+	for i, session := range sessions {
+		response[i] = gin.H{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip_address":   session.IPAddress,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == claims.SessionID,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+// RevokeSession logs out one of the user's sessions. Revoking the current
+// session is the same as logging out.
+func (h *Handler) RevokeSession(c *gin.Context) {
+	claims := currentClaims(c)
+
+	sessionID, err := uuid.Parse(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	err = h.tokens.RevokeSession(c.Request.Context(), claims.UserID, sessionID)
+	if errors.Is(err, tokens.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to revoke session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
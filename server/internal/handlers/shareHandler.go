@@ -0,0 +1,390 @@
+// internal/handlers/shareHandler.go
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultShareLinkTTL = 7 * 24 * time.Hour
+	maxShareLinkTTL     = 365 * 24 * time.Hour
+
+	// Wrong passwords allowed per link within sharePasswordWindow
+	maxSharePasswordAttempts = 10
+	sharePasswordWindow      = 15 * time.Minute
+)
+
+type ShareFileRequest struct {
+	ExpiresIn    int      `form:"expires_in" json:"expires_in"` // minutes
+	Password     string   `form:"password" json:"password"`
+	MaxDownloads int64    `form:"max_downloads" json:"max_downloads"`
+	AllowedCIDRs []string `form:"allowed_cidrs" json:"allowed_cidrs"`
+}
+
+type ShareAccessRequest struct {
+	Password string `form:"password" json:"password"`
+}
+
+type SetFileExpiryRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ShareFile creates a share link for the file. The link has its own expiry;
+// the file itself is not changed. Password, download limit and allowed
+// networks are optional.
+func (h *Handler) ShareFile(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	fileMetadata := authorizedFile(c)
+
+	var req ShareFileRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	ttl := defaultShareLinkTTL
+	if req.ExpiresIn < 0 || time.Duration(req.ExpiresIn)*time.Minute > maxShareLinkTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry"})
+		return
+	}
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Minute
+	}
+	if req.MaxDownloads < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid download limit"})
+		return
+	}
+	if len(req.Password) > 72 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is too long"})
+		return
+	}
+
+	cidrs := make([]string, 0, len(req.AllowedCIDRs))
+	for _, cidr := range req.AllowedCIDRs {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CIDR: " + cidr})
+			return
+		}
+		cidrs = append(cidrs, network.String())
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		log.Printf("Failed to generate share token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	shareLink := models.ShareLink{
+		Token:        token,
+		FileID:       fileMetadata.ID,
+		CreatedBy:    userObj.ID,
+		ExpiresAt:    time.Now().Add(ttl),
+		MaxDownloads: req.MaxDownloads,
+		AllowedCIDRs: strings.Join(cidrs, ","),
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
+		if err != nil {
+			log.Printf("Failed to hash share password: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+			return
+		}
+		shareLink.PasswordHash = string(hash)
+	}
+	if result := h.db.Create(&shareLink); result.Error != nil {
+		log.Printf("Failed to save share link: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.shareLinkResponse(shareLink))
+}
+
+func (h *Handler) ListShareLinks(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+
+	var shareLinks []models.ShareLink
+	result := h.db.Where("file_id = ?", fileMetadata.ID).Order("created_at DESC").Find(&shareLinks)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve share links"})
+		return
+	}
+
+	links := make([]gin.H, 0, len(shareLinks))
+	for _, shareLink := range shareLinks {
+		links = append(links, h.shareLinkResponse(shareLink))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id": fileMetadata.ID,
+		"links":   links,
+	})
+}
+
+func (h *Handler) RevokeShareLink(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	shareUUID, err := uuid.Parse(c.Param("share_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share link ID"})
+		return
+	}
+
+	var shareLink models.ShareLink
+	result := h.db.Where("id = ? AND created_by = ?", shareUUID, userObj.ID).First(&shareLink)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	if !shareLink.Revoked {
+		now := time.Now()
+		result = h.db.Model(&shareLink).Updates(map[string]interface{}{
+			"revoked":    true,
+			"revoked_at": now,
+		})
+		if result.Error != nil {
+			log.Printf("Failed to revoke share link: %v", result.Error)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Share link revoked",
+	})
+}
+
+// ResolveShareLink serves the file behind a share link. It is public, so
+// the link and its optional password are the only credentials. The password
+// is read from the X-Share-Password header or, for POST, the request body.
+// Every access is checked against the link's rules; only requests that start
+// a download count towards its limit.
+func (h *Handler) ResolveShareLink(c *gin.Context) {
+	var shareLink models.ShareLink
+	result := h.db.Where("token = ?", c.Param("token")).First(&shareLink)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	if shareLink.Revoked {
+		c.JSON(http.StatusGone, gin.H{"error": "Share link has been revoked"})
+		return
+	}
+	if time.Now().After(shareLink.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Share link has expired"})
+		return
+	}
+	if !shareLink.AllowsIP(c.ClientIP()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Share link is not available from this address"})
+		return
+	}
+	if shareLink.PasswordHash != "" && !h.checkSharePassword(c, &shareLink) {
+		return
+	}
+
+	// Files in the trash are not served
+	var fileMetadata models.FileMetadata
+	result = h.db.Where("id = ?", shareLink.FileID).First(&fileMetadata)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	// The object is looked up first, so a missing one doesn't use up a download
+	objectInfo, ok := h.statObject(c, fileMetadata.StorageKey())
+	if !ok {
+		return
+	}
+
+	claimed := false
+	if countsAsDownload(c, &shareLink) {
+		var err error
+		claimed, err = h.claimShareDownload(c.Request.Context(), &shareLink)
+		if err != nil {
+			log.Printf("Failed to count share link download: %v", err)
+			if shareLink.MaxDownloads > 0 {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download file"})
+				return
+			}
+		}
+		if err == nil && !claimed {
+			c.JSON(http.StatusGone, gin.H{"error": "Share link download limit reached"})
+			return
+		}
+	}
+
+	setChecksumHeaders(c, fileChecksums(&fileMetadata))
+	err := h.serveObjectInfo(c, objectInfo, path.Base(fileMetadata.FileName), fileMetadata.ContentType)
+	if err != nil && claimed {
+		h.returnShareDownload(context.Background(), &shareLink)
+	}
+}
+
+// SetFileExpiry sets or clears the time after which the file is deleted by
+// the file deletion worker.
+func (h *Handler) SetFileExpiry(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+
+	var req SetFileExpiryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry must be in the future"})
+		return
+	}
+
+	result := h.db.Model(&fileMetadata).Update("expires_at", req.ExpiresAt)
+	if result.Error != nil {
+		log.Printf("Failed to update file expiry: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file expiry"})
+		return
+	}
+
+	h.invalidateFileCaches(c.Request.Context(), fileMetadata.UserID, fileMetadata.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "File expiry updated",
+		"file_id":    fileMetadata.ID,
+		"expires_at": req.ExpiresAt,
+	})
+}
+
+// checkSharePassword verifies the password sent for a protected link. Wrong
+// passwords are counted per link so they can't be guessed quickly. It
+// writes the error response when it returns false.
+func (h *Handler) checkSharePassword(c *gin.Context, shareLink *models.ShareLink) bool {
+	password := c.GetHeader("X-Share-Password")
+	if password == "" && c.Request.Method == http.MethodPost {
+		var req ShareAccessRequest
+		if err := c.ShouldBind(&req); err == nil {
+			password = req.Password
+		}
+	}
+	if password == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Share link requires a password"})
+		return false
+	}
+
+	ctx := c.Request.Context()
+	attemptsKey := "share_password_attempts:" + shareLink.ID.String()
+	attempts, err := h.cache.Get(ctx, attemptsKey).Int()
+	if err == nil && attempts >= maxSharePasswordAttempts {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password attempts, try again later"})
+		return false
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(shareLink.PasswordHash), []byte(password))
+	if err != nil {
+		if h.cache.Incr(ctx, attemptsKey).Val() == 1 {
+			h.cache.Expire(ctx, attemptsKey, sharePasswordWindow)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect share link password"})
+		return false
+	}
+	return true
+}
+
+// countsAsDownload reports whether the request is counted against the
+// link. On a link with a download limit every request counts, since any
+// mix of ranges can add up to the whole file. Otherwise only requests
+// that fetch the file from the start count, not resumes or seeks.
+func countsAsDownload(c *gin.Context, shareLink *models.ShareLink) bool {
+	if shareLink.MaxDownloads > 0 {
+		return true
+	}
+	rangeHeader := c.GetHeader("Range")
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
+
+// claimShareDownload counts one download against the link. The counter is
+// incremented atomically in Redis, seeded from Postgres, so concurrent
+// downloads can't exceed the limit; each new count is saved to Postgres.
+// It returns false when the limit has been reached.
+func (h *Handler) claimShareDownload(ctx context.Context, shareLink *models.ShareLink) (bool, error) {
+	counterKey := "share_downloads:" + shareLink.ID.String()
+	ttl := time.Until(shareLink.ExpiresAt) + time.Hour
+	if err := h.cache.SetNX(ctx, counterKey, shareLink.DownloadCount, ttl).Err(); err != nil {
+		return false, err
+	}
+
+	count, err := h.cache.Incr(ctx, counterKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if shareLink.MaxDownloads > 0 && count > shareLink.MaxDownloads {
+		h.cache.Decr(ctx, counterKey)
+		return false, nil
+	}
+
+	result := h.db.Model(&models.ShareLink{}).Where("id = ? AND download_count < ?", shareLink.ID, count).Update("download_count", count)
+	if result.Error != nil {
+		log.Printf("Failed to save share link download count: %v", result.Error)
+	}
+	return true, nil
+}
+
+// returnShareDownload gives back a download claimed by claimShareDownload
+// that failed to be served.
+func (h *Handler) returnShareDownload(ctx context.Context, shareLink *models.ShareLink) {
+	count, err := h.cache.Decr(ctx, "share_downloads:"+shareLink.ID.String()).Result()
+	if err != nil {
+		log.Printf("Failed to give back share link download: %v", err)
+		return
+	}
+	result := h.db.Model(&models.ShareLink{}).Where("id = ? AND download_count > ?", shareLink.ID, count).Update("download_count", count)
+	if result.Error != nil {
+		log.Printf("Failed to save share link download count: %v", result.Error)
+	}
+}
+
+func (h *Handler) shareLinkResponse(shareLink models.ShareLink) gin.H {
+	return gin.H{
+		"share_id":           shareLink.ID,
+		"file_id":            shareLink.FileID,
+		"public_url":         h.shareURL(shareLink.Token),
+		"expires_at":         shareLink.ExpiresAt,
+		"revoked":            shareLink.Revoked,
+		"revoked_at":         shareLink.RevokedAt,
+		"password_protected": shareLink.PasswordHash != "",
+		"max_downloads":      shareLink.MaxDownloads,
+		"download_count":     shareLink.DownloadCount,
+		"allowed_cidrs":      shareLink.CIDRs(),
+		"created_at":         shareLink.CreatedAt,
+	}
+}
+
+// newShareToken returns a random, URL safe share link token.
+func newShareToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (h *Handler) shareURL(token string) string {
+	return h.baseURL + "/s/" + token
+}
@@ -0,0 +1,162 @@
+// internal/handlers/trashHandler.go
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) GetTrash(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var files []models.FileMetadata
+	result := h.db.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userObj.ID).Order("deleted_at DESC").Find(&files)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve trash"})
+		return
+	}
+
+	retention := h.trashRetention()
+	trashed := make([]gin.H, 0, len(files))
+	for _, file := range files {
+		trashed = append(trashed, gin.H{
+			"file":     file,
+			"purge_at": file.DeletedAt.Time.Add(retention),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files":          trashed,
+		"retention_days": int(retention.Hours() / 24),
+	})
+}
+
+func (h *Handler) RestoreFile(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	fileMetadata, ok := h.loadTrashedFile(c, userObj.ID)
+	if !ok {
+		return
+	}
+
+	// The folder may have been deleted while the file sat in the trash
+	updates := map[string]interface{}{"deleted_at": nil}
+	folderID := fileMetadata.FolderID
+	if folderID != nil {
+		if _, err := h.resolveFolderID(userObj.ID, folderID.String()); err != nil {
+			updates["folder_id"] = nil
+			folderID = nil
+		}
+	}
+
+	var existingFile models.FileMetadata
+	result := whereFolder(h.db.Where("file_name = ? AND user_id = ?", fileMetadata.FileName, userObj.ID), "folder_id", folderID).First(&existingFile)
+	if result.Error == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "File already exists"})
+		return
+	}
+
+	result = h.db.Unscoped().Model(&fileMetadata).Updates(updates)
+	if result.Error != nil {
+		log.Printf("Failed to restore file: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore file"})
+		return
+	}
+
+	h.invalidateFileCaches(c.Request.Context(), userObj.ID, fileMetadata.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "File restored successfully",
+		"file_id":   fileMetadata.ID,
+		"folder_id": folderID,
+	})
+}
+
+func (h *Handler) PurgeTrashedFile(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	fileMetadata, ok := h.loadTrashedFile(c, userObj.ID)
+	if !ok {
+		return
+	}
+
+	if err := h.purgeFile(c.Request.Context(), &fileMetadata); err != nil {
+		log.Printf("Failed to purge file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "File deleted permanently",
+	})
+}
+
+func (h *Handler) EmptyTrash(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var files []models.FileMetadata
+	result := h.db.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userObj.ID).Find(&files)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve trash"})
+		return
+	}
+
+	purged := 0
+	for _, file := range files {
+		if err := h.purgeFile(c.Request.Context(), &file); err != nil {
+			log.Printf("Failed to purge file %s: %v", file.ID, err)
+			continue
+		}
+		purged++
+	}
+	if purged < len(files) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":         "Failed to delete some files",
+			"deleted_files": purged,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Trash emptied",
+		"deleted_files": purged,
+	})
+}
+
+// loadTrashedFile finds the trashed file named in the URL. It writes the
+// error response when it returns false.
+func (h *Handler) loadTrashedFile(c *gin.Context, userID uuid.UUID) (models.FileMetadata, bool) {
+	fileUUID, err := uuid.Parse(c.Param("file_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return models.FileMetadata{}, false
+	}
+
+	var fileMetadata models.FileMetadata
+	result := h.db.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", fileUUID, userID).First(&fileMetadata)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found in trash"})
+		return models.FileMetadata{}, false
+	}
+	return fileMetadata, true
+}
+
+// trashRetention returns how long trashed files are kept.
+func (h *Handler) trashRetention() time.Duration {
+	return time.Duration(h.config.Limits.TrashRetentionDays) * 24 * time.Hour
+}
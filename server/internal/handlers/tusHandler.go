@@ -0,0 +1,603 @@
+// internal/handlers/tusHandler.go
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// tus 1.0 core protocol with the creation, termination, checksum and
+// expiration extensions. See https://tus.io/protocols/resumable-upload
+const (
+	tusVersion             = "1.0.0"
+	tusExtensions          = "creation,termination,checksum,expiration"
+	tusChecksumAlgorithms  = "md5,sha1,sha256"
+	tusMaxSize             = maxUploadParts * chunkSize
+	tusUploadTTL           = 24 * time.Hour
+	statusChecksumMismatch = 460
+)
+
+var errTusFileExists = errors.New("file already exists")
+
+func (h *Handler) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	c.Header("Tus-Max-Size", strconv.FormatInt(tusMaxSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) TusCreate(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Defer-Length is not supported"})
+		return
+	}
+	uploadLength, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || uploadLength < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Length"})
+		return
+	}
+	if uploadLength > tusMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return
+	}
+
+	rawMetadata := c.GetHeader("Upload-Metadata")
+	metadata, err := parseTusMetadata(rawMetadata)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Metadata"})
+		return
+	}
+
+	fileName := path.Base(metadata["filename"])
+	if fileName == "." || fileName == "/" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Metadata must include a filename"})
+		return
+	}
+	contentType := metadata["filetype"]
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	folderID, err := h.scopedFolderID(c, userObj.ID, metadata["folder_id"])
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	objectName := fmt.Sprintf("%s/%s", userObj.ID.String(), fileName)
+
+	if message, exists := h.fileNameConflict(userObj.ID, folderID, objectName, uuid.Nil); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": message})
+		return
+	}
+
+	if !h.checkQuota(c, userObj.ID, uploadLength) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.storage.EnsureBucket(ctx); err != nil {
+		log.Printf("Failed to ensure bucket exists: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ensure bucket exists"})
+		return
+	}
+
+	// The parts go to a key of the upload's own; the name is only metadata
+	uploadObjectName := ".uploads/" + uuid.New().String()
+	uploadID, err := h.storage.CreateMultipartUpload(ctx, uploadObjectName, storage.PutOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		log.Printf("Failed to start multipart upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start upload"})
+		return
+	}
+
+	upload := models.TusUpload{
+		UserID:       userObj.ID,
+		FolderID:     folderID,
+		FileName:     objectName,
+		ObjectName:   uploadObjectName,
+		ContentType:  contentType,
+		Metadata:     rawMetadata,
+		UploadLength: uploadLength,
+		PartSize:     chunkSize,
+		S3UploadID:   uploadID,
+		Status:       models.UploadSessionActive,
+		ExpiresAt:    time.Now().Add(tusUploadTTL),
+	}
+	if result := h.db.Create(&upload); result.Error != nil {
+		log.Printf("Failed to save tus upload: %v", result.Error)
+		if err := h.storage.AbortMultipartUpload(ctx, uploadObjectName, uploadID); err != nil {
+			log.Printf("Failed to abort multipart upload: %v", err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start upload"})
+		return
+	}
+
+	// An empty file is complete as soon as it is created
+	if uploadLength == 0 {
+		if _, err := h.finishTusUpload(ctx, &upload); err != nil {
+			respondTusFinishError(c, err)
+			return
+		}
+	}
+
+	c.Header("Location", h.baseURL+"/tus/"+upload.ID.String())
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+func (h *Handler) TusHead(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	upload, status := h.loadTusUpload(c)
+	if status != http.StatusOK {
+		c.Status(status)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.UploadOffset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.UploadLength, 10))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	if upload.Status == models.UploadSessionActive {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) TusPatch(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	if c.GetHeader("Content-Type") != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+
+	upload, status := h.loadTusUpload(c)
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": http.StatusText(status)})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Offset"})
+		return
+	}
+	if upload.Status != models.UploadSessionActive || offset != upload.UploadOffset {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the current offset"})
+		return
+	}
+
+	var checksum hash.Hash
+	var expectedChecksum []byte
+	if header := c.GetHeader("Upload-Checksum"); header != "" {
+		checksum, expectedChecksum, err = parseTusChecksum(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+
+	// tus requires that a single upload is never patched concurrently
+	lockKey := "tus_lock:" + upload.ID.String()
+	locked, err := h.cache.SetNX(ctx, lockKey, 1, 30*time.Minute).Result()
+	if err != nil {
+		log.Printf("Failed to lock tus upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write upload"})
+		return
+	}
+	if !locked {
+		c.JSON(http.StatusLocked, gin.H{"error": "Upload is locked by another request"})
+		return
+	}
+	defer h.cache.Del(context.Background(), lockKey)
+
+	var body io.Reader = io.LimitReader(c.Request.Body, upload.UploadLength-upload.UploadOffset)
+	if checksum != nil {
+		body = io.TeeReader(body, checksum)
+	}
+
+	hasher, err := restoreChecksumHasher(upload.HashState)
+	if err != nil {
+		log.Printf("Failed to load tus upload %s: %v", upload.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write upload"})
+		return
+	}
+
+	newOffset, err := h.writeTusChunk(ctx, &upload, body, hasher)
+	if err != nil {
+		log.Printf("Failed to write tus upload %s: %v", upload.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write upload"})
+		return
+	}
+
+	if checksum != nil && !bytes.Equal(checksum.Sum(nil), expectedChecksum) {
+		// Parts past the committed offset get overwritten by the retry; only
+		// the new tail object has to go
+		if newOffset != upload.UploadOffset && upload.TailLength(newOffset) > 0 {
+			err := h.storage.Delete(ctx, upload.TailObjectName(newOffset))
+			if err != nil {
+				log.Printf("Failed to delete tus tail object: %v", err)
+			}
+		}
+		c.JSON(statusChecksumMismatch, gin.H{"error": "Checksum Mismatch"})
+		return
+	}
+
+	if newOffset != upload.UploadOffset {
+		hashState, err := hasher.MarshalBinary()
+		if err != nil {
+			log.Printf("Failed to save checksum state: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write upload"})
+			return
+		}
+		result := h.db.Model(&models.TusUpload{}).
+			Where("id = ? AND upload_offset = ?", upload.ID, upload.UploadOffset).
+			Updates(map[string]interface{}{"upload_offset": newOffset, "hash_state": hashState})
+		if result.Error != nil {
+			log.Printf("Failed to update tus upload offset: %v", result.Error)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write upload"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the current offset"})
+			return
+		}
+
+		if upload.TailLength(upload.UploadOffset) > 0 {
+			err := h.storage.Delete(ctx, upload.TailObjectName(upload.UploadOffset))
+			if err != nil {
+				log.Printf("Failed to delete tus tail object: %v", err)
+			}
+		}
+		upload.UploadOffset = newOffset
+		upload.HashState = hashState
+	}
+
+	if upload.UploadOffset == upload.UploadLength {
+		if _, err := h.finishTusUpload(ctx, &upload); err != nil {
+			respondTusFinishError(c, err)
+			return
+		}
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.UploadOffset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) TusDelete(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	upload, status := h.loadTusUpload(c)
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"error": http.StatusText(status)})
+		return
+	}
+	if upload.Status != models.UploadSessionActive {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+
+	if err := h.abortTusUpload(c.Request.Context(), &upload); err != nil {
+		log.Printf("Failed to abort tus upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to terminate upload"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// checkTusResumable sets the Tus-Resumable response header and rejects
+// requests made with an unsupported protocol version.
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// loadTusUpload finds the upload named in the URL, scoped to the authenticated
+// user, and returns the status code to answer with if it can't be used.
+func (h *Handler) loadTusUpload(c *gin.Context) (models.TusUpload, int) {
+	user, _ := c.Get("user")
+	userObj, ok := user.(models.User)
+	if !ok {
+		return models.TusUpload{}, http.StatusUnauthorized
+	}
+
+	uploadUUID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		return models.TusUpload{}, http.StatusNotFound
+	}
+
+	var upload models.TusUpload
+	result := h.db.Where("id = ? AND user_id = ? AND status <> ?", uploadUUID, userObj.ID, models.UploadSessionAborted).First(&upload)
+	if result.Error != nil {
+		return models.TusUpload{}, http.StatusNotFound
+	}
+	if upload.Status == models.UploadSessionActive && time.Now().After(upload.ExpiresAt) {
+		return models.TusUpload{}, http.StatusGone
+	}
+	return upload, http.StatusOK
+}
+
+// writeTusChunk appends body to the upload and returns the new offset. Every
+// full part goes straight to the multipart upload and through hasher; the
+// remainder is saved as the tail object for the new offset and prepended to
+// the next PATCH.
+func (h *Handler) writeTusChunk(ctx context.Context, upload *models.TusUpload, body io.Reader, hasher io.Writer) (int64, error) {
+	offset := upload.UploadOffset
+	partStart := offset - upload.TailLength(offset)
+	reader := body
+	if upload.TailLength(offset) > 0 {
+		tail, _, err := h.storage.Get(ctx, upload.TailObjectName(offset), storage.GetOptions{})
+		if err != nil {
+			return offset, fmt.Errorf("error reading tail object: %v", err)
+		}
+		defer tail.Close()
+		reader = io.MultiReader(tail, body)
+	}
+
+	buffer := make([]byte, upload.PartSize)
+	for {
+		n, err := io.ReadFull(reader, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return offset, fmt.Errorf("error reading upload body: %v", err)
+		}
+
+		end := partStart + int64(n)
+		if n > 0 && (int64(n) == upload.PartSize || end == upload.UploadLength) {
+			partNumber := int(partStart/upload.PartSize) + 1
+			_, err := h.storage.UploadPart(ctx, upload.StorageKey(), upload.S3UploadID, partNumber, bytes.NewReader(buffer[:n]), int64(n))
+			if err != nil {
+				return offset, fmt.Errorf("error uploading part %d: %v", partNumber, err)
+			}
+			hasher.Write(buffer[:n])
+			partStart = end
+		} else if n > 0 {
+			_, err := h.storage.Put(ctx, upload.TailObjectName(end), bytes.NewReader(buffer[:n]), int64(n), storage.PutOptions{})
+			if err != nil {
+				return offset, fmt.Errorf("error writing tail object: %v", err)
+			}
+			return end, nil
+		}
+
+		if int64(n) < upload.PartSize {
+			return partStart, nil
+		}
+	}
+}
+
+// finishTusUpload completes the multipart upload and records the file. An
+// upload that can't be recorded because of its name or the quota is aborted,
+// as is one whose completed object can't be stored, since the client can't
+// send its last bytes again.
+func (h *Handler) finishTusUpload(ctx context.Context, upload *models.TusUpload) (models.FileMetadata, error) {
+	if upload.UploadLength == 0 {
+		_, err := h.storage.UploadPart(ctx, upload.StorageKey(), upload.S3UploadID, 1, bytes.NewReader(nil), 0)
+		if err != nil {
+			return models.FileMetadata{}, fmt.Errorf("error uploading empty part: %v", err)
+		}
+	}
+
+	if _, exists := h.fileNameConflict(upload.UserID, upload.FolderID, upload.FileName, uuid.Nil); exists {
+		h.failTusUpload(ctx, upload)
+		return models.FileMetadata{}, errTusFileExists
+	}
+
+	releaseQuota, err := h.reserveQuotaBytes(ctx, upload.UserID, upload.UploadLength)
+	if err != nil {
+		var quotaErr *quotaExceededError
+		if errors.As(err, &quotaErr) {
+			h.failTusUpload(ctx, upload)
+		}
+		return models.FileMetadata{}, err
+	}
+	defer releaseQuota()
+
+	parts, err := h.storage.ListParts(ctx, upload.StorageKey(), upload.S3UploadID)
+	if err != nil {
+		return models.FileMetadata{}, fmt.Errorf("error listing parts: %v", err)
+	}
+
+	_, err = h.storage.CompleteMultipartUpload(ctx, upload.StorageKey(), upload.S3UploadID, parts, storage.PutOptions{
+		ContentType: upload.ContentType,
+	})
+	if err != nil {
+		return models.FileMetadata{}, fmt.Errorf("error completing multipart upload: %v", err)
+	}
+
+	// The parts were hashed as they were written
+	hasher, err := restoreChecksumHasher(upload.HashState)
+	if err != nil {
+		h.failTusUpload(ctx, upload)
+		return models.FileMetadata{}, err
+	}
+	sums := hasher.Sum()
+
+	// The completed object is stored once per hash by the blobs package
+	var fileMetadata models.FileMetadata
+	_, err = h.blobs.Store(ctx, upload.StorageKey(), sums.SHA256, upload.UploadLength, func(tx *gorm.DB, blobObjectName string) error {
+		fileMetadata = models.FileMetadata{
+			FileName:       upload.FileName,
+			FileURL:        h.storage.URL(blobObjectName),
+			FileSize:       upload.UploadLength,
+			ContentType:    upload.ContentType,
+			UploadedAt:     time.Now(),
+			UserID:         upload.UserID,
+			FolderID:       upload.FolderID,
+			ObjectName:     blobObjectName,
+			ContentHash:    sums.SHA256,
+			ChecksumSHA256: sums.SHA256,
+			ChecksumCRC32C: sums.CRC32C,
+		}
+		if result := tx.Create(&fileMetadata); result.Error != nil {
+			return fmt.Errorf("error saving file metadata: %v", result.Error)
+		}
+		upload.Status = models.UploadSessionCompleted
+		if result := tx.Save(upload); result.Error != nil {
+			return fmt.Errorf("error updating tus upload: %v", result.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		h.failTusUpload(ctx, upload)
+		return models.FileMetadata{}, err
+	}
+
+	h.removeTusTemporaryObjects(ctx, upload.ID)
+	h.invalidateFileCaches(context.Background(), fileMetadata.UserID, fileMetadata.ID)
+	h.notifyQuotaThresholds(context.Background(), fileMetadata.UserID)
+
+	return fileMetadata, nil
+}
+
+func respondTusFinishError(c *gin.Context, err error) {
+	if errors.Is(err, errTusFileExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "File already exists"})
+		return
+	}
+	var quotaErr *quotaExceededError
+	if errors.As(err, &quotaErr) {
+		respondQuotaError(c, err)
+		return
+	}
+	log.Printf("Failed to finish tus upload: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete upload"})
+}
+
+// abortTusUpload releases the multipart upload, the tail objects and the
+// completed object if there is one, and marks the upload aborted.
+func (h *Handler) abortTusUpload(ctx context.Context, upload *models.TusUpload) error {
+	err := h.storage.AbortMultipartUpload(ctx, upload.StorageKey(), upload.S3UploadID)
+	if err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+		return fmt.Errorf("error aborting multipart upload: %v", err)
+	}
+	h.removeTusTemporaryObjects(ctx, upload.ID)
+	// Only a key of the upload's own is deleted; older uploads went to the
+	// file name
+	if upload.ObjectName != "" {
+		if err := h.storage.Delete(ctx, upload.ObjectName); err != nil {
+			log.Printf("Failed to delete tus upload object: %v", err)
+		}
+	}
+
+	upload.Status = models.UploadSessionAborted
+	if result := h.db.Save(upload); result.Error != nil {
+		return fmt.Errorf("error updating tus upload: %v", result.Error)
+	}
+	return nil
+}
+
+// failTusUpload aborts an upload that can't be finished, logging what it
+// can't clean up.
+func (h *Handler) failTusUpload(ctx context.Context, upload *models.TusUpload) {
+	if err := h.abortTusUpload(ctx, upload); err != nil {
+		log.Printf("Failed to abort tus upload %s: %v", upload.ID, err)
+	}
+}
+
+func (h *Handler) removeTusTemporaryObjects(ctx context.Context, uploadID uuid.UUID) {
+	err := h.storage.List(ctx, ".tus/"+uploadID.String()+"/", func(object storage.ObjectInfo) error {
+		if err := h.storage.Delete(ctx, object.Key); err != nil {
+			log.Printf("Failed to delete tus temporary object: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list tus temporary objects: %v", err)
+	}
+}
+
+// parseTusMetadata decodes an Upload-Metadata header: comma separated keys,
+// each optionally followed by a space and a base64 encoded value.
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("invalid metadata pair %q", pair)
+		}
+	}
+	return metadata, nil
+}
+
+// parseTusChecksum reads an Upload-Checksum header of the form
+// "<algorithm> <base64 digest>".
+func parseTusChecksum(header string) (hash.Hash, []byte, error) {
+	algorithm, encoded, found := strings.Cut(header, " ")
+	if !found {
+		return nil, nil, errors.New("Invalid Upload-Checksum")
+	}
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errors.New("Invalid Upload-Checksum")
+	}
+
+	switch algorithm {
+	case "md5":
+		return md5.New(), expected, nil
+	case "sha1":
+		return sha1.New(), expected, nil
+	case "sha256":
+		return sha256.New(), expected, nil
+	default:
+		return nil, nil, errors.New("Unsupported checksum algorithm")
+	}
+}
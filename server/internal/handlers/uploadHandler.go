@@ -7,15 +7,15 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"sync"
 	"time"
 
-	"github.com/ayushh2k/go-store-s3/server/internal/initializers"
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
 )
 
 const chunkSize = 5 * 1024 * 1024 // 5MB chunks
@@ -28,8 +28,8 @@ const chunkSize = 5 * 1024 * 1024 // 5MB chunks
 // 	go hub.Run()
 // }
 
-func UploadFile(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+func (h *Handler) UploadFile(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.Timeouts.Upload)
 	defer cancel()
 
 	file, header, err := c.Request.FormFile("file")
@@ -40,12 +40,17 @@ func UploadFile(c *gin.Context) {
 	}
 	defer file.Close()
 
+	expected, err := expectedChecksums(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	contentType := header.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	bucketName := os.Getenv("S3_BUCKET_NAME")
 	objectName := header.Filename
 
 	// Get the user from the context
@@ -60,24 +65,59 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
+	folderID, err := h.scopedFolderID(c, userObj.ID, c.PostForm("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
 	// Include the user's ID in the object name
 	objectName = fmt.Sprintf("%s/%s", userObj.ID.String(), objectName)
 
-	// Check if the file already exists in the database
+	// Check if the file already exists in the database. With overwrite=true
+	// the current content of a live file is kept as a version instead. Only
+	// a file in the folder the upload goes to is replaced, which keeps folder
+	// API keys to files inside their folder.
 	var existingFile models.FileMetadata
-	result := initializers.DB.Db.Where("file_name = ? AND user_id = ?", objectName, userObj.ID).First(&existingFile)
-	if result.Error == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "File already exists"})
-		return
+	overwrite := false
+	if message, exists := h.fileNameConflict(userObj.ID, folderID, objectName, uuid.Nil); exists {
+		result := whereFolder(h.db.Where("file_name = ? AND user_id = ?", objectName, userObj.ID), "folder_id", folderID).First(&existingFile)
+		if c.PostForm("overwrite") != "true" || result.Error != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": message})
+			return
+		}
+		overwrite = true
 	}
 
 	// Ensure the bucket exists, create it if it doesn't
-	if err := ensureBucketExists(ctx, bucketName); err != nil {
+	if err := h.storage.EnsureBucket(ctx); err != nil {
 		log.Printf("Failed to ensure bucket exists: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ensure bucket exists"})
 		return
 	}
 
+	// Hold the space until the metadata is saved so concurrent uploads can't overshoot the quota
+	releaseQuota, ok := h.reserveQuota(c, userObj.ID, header.Size)
+	if !ok {
+		return
+	}
+
+	var previousVersion models.FileVersion
+	if overwrite {
+		previousVersion, err = h.archiveCurrentVersion(ctx, &existingFile)
+		if err != nil {
+			releaseQuota()
+			log.Printf("Failed to keep previous version: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to keep previous version"})
+			return
+		}
+	}
+
+	// The content is hashed while it streams to a temporary object, then
+	// stored once per hash by the blobs package
+	uploadObjectName := ".uploads/" + uuid.New().String()
+	hasher := newChecksumHasher()
+
 	pipeReader, pipeWriter := io.Pipe()
 	defer pipeReader.Close()
 
@@ -100,6 +140,7 @@ func UploadFile(c *gin.Context) {
 				errChan <- fmt.Errorf("error reading file: %v", err)
 				return
 			}
+			hasher.Write(buffer[:n])
 			_, err = pipeWriter.Write(buffer[:n])
 			if err != nil {
 				errChan <- fmt.Errorf("error writing to pipe: %v", err)
@@ -108,11 +149,11 @@ func UploadFile(c *gin.Context) {
 		}
 	}()
 
-	// Goroutine for uploading to S3
-	var uploadInfo minio.UploadInfo
+	// Goroutine for uploading to storage
+	var uploadInfo storage.ObjectInfo
 	go func() {
 		defer wg.Done()
-		info, err := initializers.S3Client.PutObject(ctx, bucketName, objectName, pipeReader, header.Size, minio.PutObjectOptions{
+		info, err := h.storage.Put(ctx, uploadObjectName, pipeReader, header.Size, storage.PutOptions{
 			ContentType: contentType,
 		})
 		if err != nil {
@@ -130,79 +171,119 @@ func UploadFile(c *gin.Context) {
 
 	for err := range errChan {
 		log.Printf("Error during file upload: %v", err)
+		releaseQuota()
+		if overwrite {
+			h.discardVersion(&previousVersion)
+		}
+		h.storage.Delete(context.Background(), uploadObjectName)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file"})
 		return
 	}
 
-	// Save file metadata
-	go func() {
-		uploadDate := time.Now()
-		s3URL := fmt.Sprintf("%s/%s/%s", initializers.S3Client.EndpointURL().String(), bucketName, objectName)
-		fileMetadata := models.FileMetadata{
-			ID:          uuid.New(),
-			FileName:    objectName,
-			FileURL:     s3URL,
-			FileSize:    uploadInfo.Size,
-			ContentType: contentType,
-			UploadedAt:  uploadDate,
-			UserID:      userObj.ID,
-		}
-		result := initializers.DB.Db.Create(&fileMetadata)
-		if result.Error != nil {
-			log.Printf("Failed to save file metadata: %v", result.Error)
+	// Nothing is recorded for content that didn't arrive intact
+	sums := hasher.Sum()
+	if mismatches := mismatchedChecksums(expected, sums); len(mismatches) > 0 {
+		releaseQuota()
+		if overwrite {
+			h.discardVersion(&previousVersion)
 		}
+		h.storage.Delete(context.Background(), uploadObjectName)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      "Checksum mismatch",
+			"mismatches": mismatches,
+			"expected":   gin.H{"checksum_sha256": expected.SHA256, "checksum_crc32c": expected.CRC32C},
+			"computed":   gin.H{"checksum_sha256": sums.SHA256, "checksum_crc32c": sums.CRC32C},
+		})
+		return
+	}
 
-		// Delete the cache entry for the user's files
-		cacheKey := "files:" + userObj.ID.String()
-		err := initializers.RedisClient.Del(context.Background(), cacheKey).Err()
-		if err != nil {
-			log.Printf("Failed to delete cache entry: %v", err)
-		}
+	contentHash := sums.SHA256
+	version := 1
+	if overwrite {
+		version = existingFile.Version + 1
+	}
 
-		// Delete the shared link cache entry if it exists
-		sharedLinkCacheKey := "shared_link:" + fileMetadata.ID.String()
-		err = initializers.RedisClient.Del(context.Background(), sharedLinkCacheKey).Err()
-		if err != nil {
-			log.Printf("Failed to delete shared link cache entry: %v", err)
+	// The metadata is saved with the blob reference, and the quota is held
+	// until it is visible to storageUsed
+	defer h.notifyQuotaThresholds(context.Background(), userObj.ID)
+	defer releaseQuota()
+
+	uploadDate := time.Now()
+	fileMetadata := existingFile
+	blobObjectName, err := h.blobs.Store(ctx, uploadObjectName, contentHash, uploadInfo.Size, func(tx *gorm.DB, blobObjectName string) error {
+		fileURL := h.storage.URL(blobObjectName)
+		if overwrite {
+			previousKey, previousHash := existingFile.StorageKey(), existingFile.ContentHash
+			updates := map[string]interface{}{
+				"file_url":         fileURL,
+				"file_size":        uploadInfo.Size,
+				"content_type":     contentType,
+				"uploaded_at":      uploadDate,
+				"version":          version,
+				"object_name":      blobObjectName,
+				"content_hash":     contentHash,
+				"checksum_sha256":  sums.SHA256,
+				"checksum_crc32_c": sums.CRC32C,
+			}
+			if result := tx.Model(&fileMetadata).Updates(updates); result.Error != nil {
+				return fmt.Errorf("error updating file metadata: %v", result.Error)
+			}
+			if err := dropReplacedContentTx(tx, previousKey, previousHash, blobObjectName); err != nil {
+				return err
+			}
+			return outbox.InvalidateFileCaches(tx, userObj.ID, fileMetadata.ID)
 		}
 
-		// Notify the user via WebSocket
-		// if client, ok := hub.Clients[userObj.ID]; ok {
-		// 	message := []byte(fmt.Sprintf("File upload completed: %s", objectName))
-		// 	client.Send <- message
-		// }
-	}()
+		fileMetadata = models.FileMetadata{
+			ID:             uuid.New(),
+			FileName:       objectName,
+			FileURL:        fileURL,
+			FileSize:       uploadInfo.Size,
+			ContentType:    contentType,
+			UploadedAt:     uploadDate,
+			UserID:         userObj.ID,
+			FolderID:       folderID,
+			ObjectName:     blobObjectName,
+			ContentHash:    contentHash,
+			ChecksumSHA256: sums.SHA256,
+			ChecksumCRC32C: sums.CRC32C,
+		}
+		if result := tx.Create(&fileMetadata); result.Error != nil {
+			return fmt.Errorf("error saving file metadata: %v", result.Error)
+		}
+		return outbox.InvalidateFileCaches(tx, userObj.ID, fileMetadata.ID)
+	})
+	if err != nil {
+		log.Printf("Failed to store file: %v", err)
+		if overwrite {
+			h.discardVersion(&previousVersion)
+		}
+		h.storage.Delete(context.Background(), uploadObjectName)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file"})
+		return
+	}
+	fileURL := h.storage.URL(blobObjectName)
 
 	// Invalidate the cache for the user's search results
-	invalidateCache(context.Background(), userObj.ID)
+	h.invalidateCache(context.Background(), userObj.ID)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":    "File uploaded successfully",
-		"filename":   objectName,
-		"file_size":  uploadInfo.Size,
-		"upload_url": fmt.Sprintf("%s/%s/%s", initializers.S3Client.EndpointURL().String(), bucketName, objectName),
+		"message":         "File uploaded successfully",
+		"filename":        objectName,
+		"file_size":       uploadInfo.Size,
+		"version":         version,
+		"content_hash":    contentHash,
+		"checksum_sha256": sums.SHA256,
+		"checksum_crc32c": sums.CRC32C,
+		"upload_url":      fileURL,
 	})
 }
 
-func ensureBucketExists(ctx context.Context, bucketName string) error {
-	exists, err := initializers.S3Client.BucketExists(ctx, bucketName)
-	if err != nil {
-		return fmt.Errorf("error checking bucket existence: %v", err)
-	}
-	if !exists {
-		err = initializers.S3Client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{Region: "ap-south-1"})
-		if err != nil {
-			return fmt.Errorf("error creating bucket: %v", err)
-		}
-	}
-	return nil
-}
-
-func invalidateCache(ctx context.Context, userID uuid.UUID) {
+func (h *Handler) invalidateCache(ctx context.Context, userID uuid.UUID) {
 	cacheKeyPattern := "search_results:" + userID.String() + ":*"
-	iter := initializers.RedisClient.Scan(ctx, 0, cacheKeyPattern, 0).Iterator()
+	iter := h.cache.Scan(ctx, 0, cacheKeyPattern, 0).Iterator()
 	for iter.Next(ctx) {
-		err := initializers.RedisClient.Del(ctx, iter.Val()).Err()
+		err := h.cache.Del(ctx, iter.Val()).Err()
 		if err != nil {
 			log.Printf("Failed to delete cache entry: %v", err)
 		}
@@ -0,0 +1,423 @@
+// internal/handlers/uploadSessionHandler.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxUploadParts   = 10000
+	maxPartSize      = 5 * 1024 * 1024 * 1024 // 5GB, the S3 limit for a single part
+	uploadSessionTTL = 7 * 24 * time.Hour
+)
+
+type CreateUploadSessionRequest struct {
+	FileName    string `json:"file_name" binding:"required"`
+	FileSize    int64  `json:"file_size" binding:"min=0"`
+	ContentType string `json:"content_type"`
+	PartSize    int64  `json:"part_size"`
+	FolderID    string `json:"folder_id"`
+}
+
+func (h *Handler) CreateUploadSession(c *gin.Context) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var request CreateUploadSessionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	fileName := path.Base(request.FileName)
+	if fileName == "." || fileName == "/" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file name"})
+		return
+	}
+
+	contentType := request.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	partSize := choosePartSize(request.FileSize, request.PartSize)
+	if int64(maxUploadParts)*partSize < request.FileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is too large"})
+		return
+	}
+
+	folderID, err := h.scopedFolderID(c, userObj.ID, request.FolderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	objectName := fmt.Sprintf("%s/%s", userObj.ID.String(), fileName)
+
+	if message, exists := h.fileNameConflict(userObj.ID, folderID, objectName, uuid.Nil); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": message})
+		return
+	}
+
+	if !h.checkQuota(c, userObj.ID, request.FileSize) {
+		return
+	}
+
+	// Only one upload per file may be in flight; point the client at it so it can resume
+	var activeSession models.UploadSession
+	result := whereFolder(h.db.Where("file_name = ? AND user_id = ? AND status = ?", objectName, userObj.ID, models.UploadSessionActive), "folder_id", folderID).First(&activeSession)
+	if result.Error == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "An upload for this file is already in progress",
+			"session_id": activeSession.ID,
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.storage.EnsureBucket(ctx); err != nil {
+		log.Printf("Failed to ensure bucket exists: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ensure bucket exists"})
+		return
+	}
+
+	// The parts go to a key of the session's own; the name is only metadata
+	uploadObjectName := ".uploads/" + uuid.New().String()
+	uploadID, err := h.storage.CreateMultipartUpload(ctx, uploadObjectName, storage.PutOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		log.Printf("Failed to start multipart upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start upload"})
+		return
+	}
+
+	session := models.UploadSession{
+		UserID:      userObj.ID,
+		FolderID:    folderID,
+		FileName:    objectName,
+		ObjectName:  uploadObjectName,
+		ContentType: contentType,
+		FileSize:    request.FileSize,
+		PartSize:    partSize,
+		S3UploadID:  uploadID,
+		Status:      models.UploadSessionActive,
+		ExpiresAt:   time.Now().Add(uploadSessionTTL),
+	}
+	if result := h.db.Create(&session); result.Error != nil {
+		log.Printf("Failed to save upload session: %v", result.Error)
+		if err := h.storage.AbortMultipartUpload(ctx, uploadObjectName, uploadID); err != nil {
+			log.Printf("Failed to abort multipart upload: %v", err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start upload"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"session_id":  session.ID,
+		"filename":    session.FileName,
+		"file_size":   session.FileSize,
+		"part_size":   session.PartSize,
+		"total_parts": session.TotalParts(),
+		"expires_at":  session.ExpiresAt,
+	})
+}
+
+func (h *Handler) GetUploadSession(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"session_id":  session.ID,
+		"filename":    session.FileName,
+		"file_size":   session.FileSize,
+		"part_size":   session.PartSize,
+		"total_parts": session.TotalParts(),
+		"status":      session.Status,
+		"expires_at":  session.ExpiresAt,
+	}
+
+	if session.Status == models.UploadSessionActive {
+		parts, err := h.storage.ListParts(c.Request.Context(), session.StorageKey(), session.S3UploadID)
+		if err != nil {
+			log.Printf("Failed to list uploaded parts: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list uploaded parts"})
+			return
+		}
+
+		received := make([]gin.H, 0, len(parts))
+		var receivedBytes int64
+		for _, part := range parts {
+			received = append(received, gin.H{
+				"part_number": part.PartNumber,
+				"size":        part.Size,
+				"etag":        part.ETag,
+			})
+			receivedBytes += part.Size
+		}
+		response["parts"] = received
+		response["received_bytes"] = receivedBytes
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) UploadPart(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Status != models.UploadSessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload session is not active"})
+		return
+	}
+
+	partNumber, err := strconv.Atoi(c.Param("part_number"))
+	if err != nil || partNumber < 1 || partNumber > session.TotalParts() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid part number"})
+		return
+	}
+
+	expectedSize := session.PartLength(partNumber)
+	if c.Request.ContentLength != expectedSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":         "Invalid part size",
+			"expected_size": expectedSize,
+		})
+		return
+	}
+
+	part, err := h.storage.UploadPart(c.Request.Context(), session.StorageKey(), session.S3UploadID, partNumber, c.Request.Body, expectedSize)
+	if err != nil {
+		log.Printf("Failed to upload part %d of session %s: %v", partNumber, session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload part"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"part_number": partNumber,
+		"size":        part.Size,
+		"etag":        part.ETag,
+	})
+}
+
+func (h *Handler) CompleteUploadSession(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Status != models.UploadSessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload session is not active"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Guard against two clients completing the same session at once
+	lockKey := "upload_session_lock:" + session.ID.String()
+	locked, err := h.cache.SetNX(ctx, lockKey, 1, 10*time.Minute).Result()
+	if err != nil {
+		log.Printf("Failed to lock upload session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete upload"})
+		return
+	}
+	if !locked {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload session is already being completed"})
+		return
+	}
+	defer h.cache.Del(context.Background(), lockKey)
+
+	parts, err := h.storage.ListParts(ctx, session.StorageKey(), session.S3UploadID)
+	if err != nil {
+		log.Printf("Failed to list uploaded parts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list uploaded parts"})
+		return
+	}
+
+	received := make(map[int]storage.Part, len(parts))
+	for _, part := range parts {
+		received[part.PartNumber] = part
+	}
+
+	var missing []int
+	completeParts := make([]storage.Part, 0, session.TotalParts())
+	for partNumber := 1; partNumber <= session.TotalParts(); partNumber++ {
+		part, ok := received[partNumber]
+		if !ok || part.Size != session.PartLength(partNumber) {
+			missing = append(missing, partNumber)
+			continue
+		}
+		completeParts = append(completeParts, part)
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":         "Upload is incomplete",
+			"missing_parts": missing,
+		})
+		return
+	}
+
+	if message, exists := h.fileNameConflict(session.UserID, session.FolderID, session.FileName, uuid.Nil); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": message})
+		return
+	}
+
+	// The quota may have filled up while the parts were uploaded
+	releaseQuota, ok := h.reserveQuota(c, session.UserID, session.FileSize)
+	if !ok {
+		return
+	}
+	defer releaseQuota()
+
+	uploadInfo, err := h.storage.CompleteMultipartUpload(ctx, session.StorageKey(), session.S3UploadID, completeParts, storage.PutOptions{
+		ContentType: session.ContentType,
+	})
+	if err != nil {
+		log.Printf("Failed to complete multipart upload: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete upload"})
+		return
+	}
+
+	// The parts went straight to storage, so the checksums are computed by
+	// reading the object back
+	hasher := newChecksumHasher()
+	if _, err := h.hashObject(ctx, session.StorageKey(), hasher); err != nil {
+		log.Printf("Failed to hash uploaded object: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete upload"})
+		return
+	}
+	sums := hasher.Sum()
+
+	// The completed object is stored once per hash by the blobs package
+	var fileMetadata models.FileMetadata
+	blobObjectName, err := h.blobs.Store(ctx, session.StorageKey(), sums.SHA256, uploadInfo.Size, func(tx *gorm.DB, blobObjectName string) error {
+		fileMetadata = models.FileMetadata{
+			FileName:       session.FileName,
+			FileURL:        h.storage.URL(blobObjectName),
+			FileSize:       session.FileSize,
+			ContentType:    session.ContentType,
+			UploadedAt:     time.Now(),
+			UserID:         session.UserID,
+			FolderID:       session.FolderID,
+			ObjectName:     blobObjectName,
+			ContentHash:    sums.SHA256,
+			ChecksumSHA256: sums.SHA256,
+			ChecksumCRC32C: sums.CRC32C,
+		}
+		if result := tx.Create(&fileMetadata); result.Error != nil {
+			return fmt.Errorf("error saving file metadata: %v", result.Error)
+		}
+		session.Status = models.UploadSessionCompleted
+		if result := tx.Save(&session); result.Error != nil {
+			return fmt.Errorf("error updating upload session: %v", result.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to store file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file metadata"})
+		return
+	}
+	fileURL := h.storage.URL(blobObjectName)
+
+	h.invalidateFileCaches(context.Background(), fileMetadata.UserID, fileMetadata.ID)
+	h.notifyQuotaThresholds(context.Background(), fileMetadata.UserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "File uploaded successfully",
+		"file_id":         fileMetadata.ID,
+		"filename":        fileMetadata.FileName,
+		"file_size":       fileMetadata.FileSize,
+		"etag":            uploadInfo.ETag,
+		"checksum_sha256": sums.SHA256,
+		"checksum_crc32c": sums.CRC32C,
+		"upload_url":      fileURL,
+	})
+}
+
+func (h *Handler) AbortUploadSession(c *gin.Context) {
+	session, ok := h.loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Status != models.UploadSessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload session is not active"})
+		return
+	}
+
+	if err := h.abortUploadSession(c.Request.Context(), &session); err != nil {
+		log.Printf("Failed to abort upload session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to abort upload"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Upload aborted",
+	})
+}
+
+// loadUploadSession finds the session named in the URL, scoped to the
+// authenticated user. It writes the error response when it returns false.
+func (h *Handler) loadUploadSession(c *gin.Context) (models.UploadSession, bool) {
+	userObj, ok := currentUser(c)
+	if !ok {
+		return models.UploadSession{}, false
+	}
+
+	sessionUUID, err := uuid.Parse(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return models.UploadSession{}, false
+	}
+
+	var session models.UploadSession
+	result := h.db.Where("id = ? AND user_id = ?", sessionUUID, userObj.ID).First(&session)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload session not found"})
+		return models.UploadSession{}, false
+	}
+	return session, true
+}
+
+func (h *Handler) abortUploadSession(ctx context.Context, session *models.UploadSession) error {
+	err := h.storage.AbortMultipartUpload(ctx, session.StorageKey(), session.S3UploadID)
+	if err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+		return err
+	}
+
+	session.Status = models.UploadSessionAborted
+	return h.db.Save(session).Error
+}
+
+// choosePartSize picks a part size of at least chunkSize that keeps the
+// upload within the S3 limit of 10000 parts.
+func choosePartSize(fileSize, requested int64) int64 {
+	partSize := int64(chunkSize)
+	if requested > partSize {
+		partSize = requested
+	}
+	if minimum := (fileSize + maxUploadParts - 1) / maxUploadParts; minimum > partSize {
+		partSize = minimum
+	}
+	if partSize > maxPartSize {
+		partSize = maxPartSize
+	}
+	return partSize
+}
@@ -3,12 +3,11 @@ package handlers
 import (
 	"net/http"
 
-	"github.com/ayushh2k/go-store-s3/server/internal/initializers"
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
 	"github.com/gin-gonic/gin"
 )
 
-func GetUserEmail(c *gin.Context) {
+func (h *Handler) GetUserEmail(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -25,7 +24,7 @@ func GetUserEmail(c *gin.Context) {
 	})
 }
 
-func GetTotalFiles(c *gin.Context) {
+func (h *Handler) GetTotalFiles(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -38,7 +37,7 @@ func GetTotalFiles(c *gin.Context) {
 	}
 
 	var totalFiles int64
-	result := initializers.DB.Db.Model(&models.FileMetadata{}).Where("user_id = ?", userObj.ID).Count(&totalFiles)
+	result := h.db.Model(&models.FileMetadata{}).Where("user_id = ?", userObj.ID).Count(&totalFiles)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve total files"})
 		return
@@ -49,7 +48,7 @@ func GetTotalFiles(c *gin.Context) {
 	})
 }
 
-func GetStorageUsed(c *gin.Context) {
+func (h *Handler) GetStorageUsed(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -61,14 +60,16 @@ func GetStorageUsed(c *gin.Context) {
 		return
 	}
 
-	var totalStorage int64
-	result := initializers.DB.Db.Model(&models.FileMetadata{}).Where("user_id = ?", userObj.ID).Select("SUM(file_size)").Row().Scan(&totalStorage)
-	if result != nil {
+	usage, err := h.storageUsed(userObj.ID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve storage used"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"storage_used": totalStorage,
+		"storage_used":     usage.Total(),
+		"files_storage":    usage.Files,
+		"trash_storage":    usage.Trash,
+		"versions_storage": usage.Versions,
 	})
 }
@@ -0,0 +1,235 @@
+// internal/handlers/versionHandler.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/blobs"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (h *Handler) ListFileVersions(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+
+	var versions []models.FileVersion
+	result := h.db.Where("file_id = ?", fileMetadata.ID).Order("version_number DESC").Find(&versions)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":         fileMetadata.ID,
+		"current_version": fileMetadata.Version,
+		"versions":        versions,
+	})
+}
+
+func (h *Handler) DownloadFileVersion(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+	version, ok := h.loadFileVersion(c, fileMetadata.ID)
+	if !ok {
+		return
+	}
+
+	setChecksumHeaders(c, versionChecksums(&version))
+	h.serveObject(c, version.ObjectName, path.Base(fileMetadata.FileName), version.ContentType)
+}
+
+// RestoreFileVersion makes an earlier version the current content. The
+// content it replaces is kept as a new version, so a restore can be undone.
+func (h *Handler) RestoreFileVersion(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+	version, ok := h.loadFileVersion(c, fileMetadata.ID)
+	if !ok {
+		return
+	}
+
+	// The current content stays as a version and the restored one keeps its
+	// row too, so usage grows by the size of the restored content
+	releaseQuota, ok := h.reserveQuota(c, fileMetadata.UserID, version.FileSize)
+	if !ok {
+		return
+	}
+	defer releaseQuota()
+
+	ctx := c.Request.Context()
+	previousVersion, err := h.archiveCurrentVersion(ctx, &fileMetadata)
+	if err != nil {
+		log.Printf("Failed to keep previous version: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to keep previous version"})
+		return
+	}
+
+	// Deduplicated versions share their blob with the file; older ones are
+	// copied to a new object, which the file switches to with the update
+	currentVersion := fileMetadata.Version + 1
+	updates := map[string]interface{}{
+		"file_size":        version.FileSize,
+		"content_type":     version.ContentType,
+		"uploaded_at":      time.Now(),
+		"version":          currentVersion,
+		"object_name":      version.ObjectName,
+		"content_hash":     version.ContentHash,
+		"checksum_sha256":  version.ChecksumSHA256,
+		"checksum_crc32_c": version.ChecksumCRC32C,
+	}
+	if version.ContentHash != "" {
+		err = h.blobs.Acquire(ctx, version.ContentHash)
+	} else {
+		restoredObjectName := models.RestoredObjectName(fileMetadata.ID, currentVersion)
+		_, err = h.storage.Copy(ctx, version.ObjectName, restoredObjectName)
+		updates["object_name"] = restoredObjectName
+	}
+	if err != nil {
+		log.Printf("Failed to restore version: %v", err)
+		h.discardVersion(&previousVersion)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore version"})
+		return
+	}
+
+	previousKey, previousHash := fileMetadata.StorageKey(), fileMetadata.ContentHash
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Model(&fileMetadata).Updates(updates); result.Error != nil {
+			return result.Error
+		}
+		if err := dropReplacedContentTx(tx, previousKey, previousHash, fileMetadata.StorageKey()); err != nil {
+			return err
+		}
+		return outbox.InvalidateFileCaches(tx, fileMetadata.UserID, fileMetadata.ID)
+	})
+	if err != nil {
+		log.Printf("Failed to update file metadata: %v", err)
+		if version.ContentHash != "" {
+			h.blobs.Release(context.Background(), version.ContentHash)
+		} else {
+			h.storage.Delete(context.Background(), updates["object_name"].(string))
+		}
+		h.discardVersion(&previousVersion)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore version"})
+		return
+	}
+	h.outbox.Notify()
+	h.notifyQuotaThresholds(context.Background(), fileMetadata.UserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Version restored successfully",
+		"file_id":         fileMetadata.ID,
+		"restored":        version.VersionNumber,
+		"current_version": currentVersion,
+	})
+}
+
+func (h *Handler) DeleteFileVersion(c *gin.Context) {
+	fileMetadata := authorizedFile(c)
+	version, ok := h.loadFileVersion(c, fileMetadata.ID)
+	if !ok {
+		return
+	}
+
+	if err := h.deleteVersion(&version); err != nil {
+		log.Printf("Failed to delete version: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete version"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Version deleted successfully",
+	})
+}
+
+// loadFileVersion finds the version named in the URL. It writes the error
+// response when it returns false.
+func (h *Handler) loadFileVersion(c *gin.Context, fileID uuid.UUID) (models.FileVersion, bool) {
+	versionUUID, err := uuid.Parse(c.Param("version_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version ID"})
+		return models.FileVersion{}, false
+	}
+
+	var version models.FileVersion
+	result := h.db.Where("id = ? AND file_id = ?", versionUUID, fileID).First(&version)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return models.FileVersion{}, false
+	}
+	return version, true
+}
+
+// archiveCurrentVersion records the file's current content as a version
+// before it is replaced. Deduplicated content gains a reference to its blob;
+// older content is copied to the version key.
+func (h *Handler) archiveCurrentVersion(ctx context.Context, fileMetadata *models.FileMetadata) (models.FileVersion, error) {
+	objectName := fileMetadata.ObjectName
+	if fileMetadata.ContentHash != "" {
+		if err := h.blobs.Acquire(ctx, fileMetadata.ContentHash); err != nil {
+			return models.FileVersion{}, fmt.Errorf("error referencing current version: %v", err)
+		}
+	} else {
+		objectName = models.VersionObjectName(fileMetadata.ID, fileMetadata.Version)
+		_, err := h.storage.Copy(ctx, fileMetadata.StorageKey(), objectName)
+		if err != nil {
+			return models.FileVersion{}, fmt.Errorf("error copying current version: %v", err)
+		}
+	}
+
+	version := models.FileVersion{
+		FileID:         fileMetadata.ID,
+		UserID:         fileMetadata.UserID,
+		VersionNumber:  fileMetadata.Version,
+		ObjectName:     objectName,
+		ContentHash:    fileMetadata.ContentHash,
+		ChecksumSHA256: fileMetadata.ChecksumSHA256,
+		ChecksumCRC32C: fileMetadata.ChecksumCRC32C,
+		FileSize:       fileMetadata.FileSize,
+		ContentType:    fileMetadata.ContentType,
+		UploadedAt:     fileMetadata.UploadedAt,
+	}
+	if result := h.db.Create(&version); result.Error != nil {
+		h.blobs.Drop(ctx, objectName, fileMetadata.ContentHash)
+		return models.FileVersion{}, fmt.Errorf("error saving version metadata: %v", result.Error)
+	}
+	return version, nil
+}
+
+// discardVersion undoes archiveCurrentVersion when the new content could not
+// be written.
+func (h *Handler) discardVersion(version *models.FileVersion) {
+	if err := h.deleteVersion(version); err != nil {
+		log.Printf("Failed to delete version: %v", err)
+	}
+}
+
+// deleteVersion deletes the version's row and drops its content.
+func (h *Handler) deleteVersion(version *models.FileVersion) error {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := blobs.DropTx(tx, version.ObjectName, version.ContentHash); err != nil {
+			return fmt.Errorf("error deleting version content: %v", err)
+		}
+		if result := tx.Delete(version); result.Error != nil {
+			return fmt.Errorf("error deleting version metadata: %v", result.Error)
+		}
+		return nil
+	})
+	h.outbox.Notify()
+	return err
+}
+
+// dropReplacedContentTx releases the content a file pointed at before it was
+// replaced. Older content stored under the file's own name is only removed
+// when the new content lives elsewhere.
+func dropReplacedContentTx(tx *gorm.DB, previousKey string, previousHash string, currentKey string) error {
+	if previousHash == "" && previousKey == currentKey {
+		return nil
+	}
+	return blobs.DropTx(tx, previousKey, previousHash)
+}
@@ -3,11 +3,18 @@ package initializers
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 
+	"github.com/ayushh2k/go-store-s3/server/internal/config"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/glebarez/sqlite"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/redis/go-redis/v9"
@@ -16,21 +23,34 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-type DBinstance struct {
-	Db *gorm.DB
-}
+// ConnectToDb opens the configured database: PostgreSQL, or SQLite for a
+// single file.
+func ConnectToDb(cfg config.DatabaseConfig) *gorm.DB {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case "sqlite":
+		if err := os.MkdirAll(filepath.Dir(cfg.SQLitePath), 0o755); err != nil {
+			log.Fatalf("Failed to create database directory: %v", err)
+		}
+		// WAL lets the workers read while a request writes, and the busy
+		// timeout makes concurrent writers wait instead of failing
+		dialector = sqlite.Open(cfg.SQLitePath + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+		log.Printf("Using SQLite database at %s", cfg.SQLitePath)
+	default:
+		dsn := fmt.Sprintf(
+			"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
+			cfg.Host,
+			cfg.User,
+			cfg.Password,
+			cfg.Name,
+			cfg.Port,
+			cfg.SSLMode,
+			cfg.TimeZone,
+		)
+		dialector = postgres.Open(dsn)
+	}
 
-var DB DBinstance
-
-func ConnectToDb() {
-	var err error
-	dsn := fmt.Sprintf(
-		"host=db user=%s password=%s dbname=%s port=5432 sslmode=disable TimeZone=Asia/Kolkata",
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-	)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 
@@ -41,50 +61,90 @@ func ConnectToDb() {
 	log.Print("Connected to database")
 	db.Logger = logger.Default.LogMode(logger.Info)
 
-	// log.Print("Running migrations")
-	// db.AutoMigrate(&models.User{})
-	DB = DBinstance{Db: db}
+	return db
 }
 
-var S3Client *minio.Client
+// ConnectStorage sets up the configured backend: S3, a local directory or
+// memory.
+func ConnectStorage(cfg config.StorageConfig) storage.Backend {
+	switch cfg.Backend {
+	case "local":
+		log.Printf("Storing files under %s", cfg.LocalPath)
+		return storage.NewLocalBackend(cfg.LocalPath)
+	case "memory":
+		log.Println("Storing files in memory")
+		return storage.NewMemoryBackend()
+	default:
+		return connectS3(cfg.S3)
+	}
+}
 
-func ConnectS3() {
-	endpoint := os.Getenv("S3_ENDPOINT")
-	accessKeyID := os.Getenv("S3_ACCESS_KEY")
-	secretAccessKey := os.Getenv("S3_SECRET_KEY")
-	useSSL := false
+func connectS3(cfg config.S3Config) storage.Backend {
+	options := &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	}
+	if cfg.PathStyle {
+		options.BucketLookup = minio.BucketLookupPath
+	}
+	if cfg.CAFile != "" {
+		transport, err := s3Transport(cfg.CAFile)
+		if err != nil {
+			log.Fatalf("Failed to load S3 CA file: %v", err)
+		}
+		options.Transport = transport
+	}
 
 	// Initialize S3 client
-	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
-		Secure: useSSL,
-	})
+	client, err := minio.New(cfg.Endpoint, options)
 	if err != nil {
 		log.Fatalf("Failed to connect to S3: %v", err)
 	}
 
-	S3Client = client
 	log.Println("Connected to S3 successfully")
+	return storage.NewMinioBackend(client, cfg.Bucket, cfg.Region)
 }
 
-var RedisClient *redis.Client
+// s3Transport trusts the certificates in caFile as well as the system
+// roots.
+func s3Transport(caFile string) (*http.Transport, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
 
-func ConnectRedis() {
-	redisHost := os.Getenv("REDIS_HOST")
-	redisPort := os.Getenv("REDIS_PORT")
-	redisDB, err := strconv.Atoi(os.Getenv("REDIS_DB"))
+	transport, err := minio.DefaultTransport(true)
 	if err != nil {
-		log.Fatalf("Failed to parse REDIS_DB: %v", err)
+		return nil, err
+	}
+	transport.TLSClientConfig.RootCAs = pool
+	return transport, nil
+}
+
+// ConnectRedis connects to the Redis server, or with the memory backend
+// starts an in-process one that keeps the cache in memory until ctx is done.
+func ConnectRedis(ctx context.Context, cfg config.CacheConfig) *redis.Client {
+	if cfg.Backend == "memory" {
+		return startMemoryCache(ctx)
 	}
 
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     redisHost + ":" + redisPort,
-		Password: os.Getenv("REDIS_PASSWORD"),
-		DB:       redisDB,
+	client := redis.NewClient(&redis.Options{
+		Addr:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	})
 
-	_, err = RedisClient.Ping(context.Background()).Result()
+	_, err := client.Ping(context.Background()).Result()
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
+	return client
 }
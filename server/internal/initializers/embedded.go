@@ -0,0 +1,41 @@
+// internal/initializers/embedded.go
+package initializers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// startMemoryCache runs a Redis compatible server inside the process, so
+// the cache, locks and counters work unchanged without a Redis server. It
+// is meant for development and trying the server out on one machine: the
+// data is lost on restart and isn't shared between processes. The server
+// is closed once ctx is done.
+func startMemoryCache(ctx context.Context) *redis.Client {
+	server, err := miniredis.Run()
+	if err != nil {
+		log.Fatalf("Failed to start in-memory cache: %v", err)
+	}
+
+	// miniredis only expires keys when its clock is moved forward
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		defer server.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				server.FastForward(time.Second)
+			}
+		}
+	}()
+
+	log.Println("Using in-memory cache, for development only")
+	return redis.NewClient(&redis.Options{Addr: server.Addr()})
+}
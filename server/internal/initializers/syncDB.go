@@ -5,11 +5,12 @@ import (
 	"log"
 
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"gorm.io/gorm"
 )
 
-func SyncDatabase() {
+func SyncDatabase(db *gorm.DB) {
 	log.Print("Running migrations...")
-	err := DB.Db.AutoMigrate(&models.User{}, &models.FileMetadata{})
+	err := db.AutoMigrate(&models.User{}, &models.FileMetadata{}, &models.Folder{}, &models.FileVersion{}, &models.Blob{}, &models.ShareLink{}, &models.Permission{}, &models.QuotaEvent{}, &models.OutboxEvent{}, &models.UploadSession{}, &models.TusUpload{}, &models.RefreshToken{}, &models.Session{}, &models.APIKey{}, &models.UserIdentity{})
 	if err != nil {
 		log.Fatalf("Failed to auto-migrate database: %v", err)
 	}
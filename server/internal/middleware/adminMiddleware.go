@@ -0,0 +1,33 @@
+// internal/middleware/adminMiddleware.go
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware lets the request through only for users whose email is
+// listed in the admin emails setting. It has to run after AuthMiddleware.
+func (m *Middleware) AdminMiddleware(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	userObj, ok := user.(models.User)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Invalid user object"})
+		return
+	}
+
+	for _, email := range m.config.Auth.AdminEmails {
+		if strings.EqualFold(email, userObj.Email) {
+			c.Next()
+			return
+		}
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+}
@@ -2,20 +2,26 @@
 package middleware
 
 import (
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
-	"github.com/ayushh2k/go-store-s3/server/internal/initializers"
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
-func AuthMiddleware(c *gin.Context) {
+// AuthMiddleware accepts a valid, unrevoked access token and puts its user
+// in the context as "user" and its claims as "claims". It also records the
+// session's activity. API keys are accepted too, in the X-API-Key header or
+// as the bearer token, for the routes their scope allows.
+func (m *Middleware) AuthMiddleware(c *gin.Context) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		m.authenticateAPIKey(c, key)
+		return
+	}
+
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
@@ -30,69 +36,47 @@ func AuthMiddleware(c *gin.Context) {
 		return
 	}
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokens.IsAPIKey(tokenString) {
+		m.authenticateAPIKey(c, tokenString)
+		return
+	}
 
-	// Parse the JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-
-	// Handle invalid token or parsing errors
-	if err != nil || !token.Valid {
+	claims, err := m.tokens.Parse(c.Request.Context(), tokenString)
+	switch {
+	case errors.Is(err, tokens.ErrTokenRevoked):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Token has been revoked",
+		})
+		return
+	case errors.Is(err, tokens.ErrInvalidToken):
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid or expired token",
 		})
 		return
+	case err != nil:
+		log.Printf("Failed to verify token: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify token",
+		})
+		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Token has expired",
-			})
-			return
-		}
-
-		// Extract the user ID from the claims
-		var userIDString string
-		switch sub := claims["sub"].(type) {
-		case string:
-			userIDString = sub
-		case float64:
-			userIDString = fmt.Sprintf("%f", sub)
-		default:
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid user ID in token",
-			})
-			return
-		}
-
-		userID, err := uuid.Parse(userIDString)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid user ID in token",
-			})
-			return
-		}
-
-		var user models.User
-		result := initializers.DB.Db.First(&user, "id = ?", userID)
-
-		// Check if user exists
-		if result.Error != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "User not found",
-			})
-			return
-		}
+	var user models.User
+	result := m.db.First(&user, "id = ?", claims.UserID)
 
-		c.Set("user", user)
-		c.Next()
-	} else {
+	// Check if user exists
+	if result.Error != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid token claims",
+			"error": "User not found",
 		})
+		return
+	}
+
+	if err := m.tokens.Touch(c.Request.Context(), claims.SessionID); err != nil {
+		log.Printf("Failed to record session activity: %v", err)
 	}
+
+	c.Set("user", user)
+	c.Set("claims", claims)
+	c.Next()
 }
@@ -0,0 +1,133 @@
+// internal/middleware/authorizeMiddleware.go
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/authz"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AuthorizeFile loads the file named by the file_id URL parameter and lets
+// the request through only if the user may perform action on it. Files the
+// user can't see are reported as not found, so their IDs leak nothing.
+// Handlers read the file from the context key "file". It has to run after
+// AuthMiddleware.
+func (m *Middleware) AuthorizeFile(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fileUUID, err := uuid.Parse(c.Param("file_id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+			return
+		}
+
+		var fileMetadata models.FileMetadata
+		result := m.db.Where("id = ?", fileUUID).First(&fileMetadata)
+		if result.Error != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+
+		if !m.authorize(c, action, &fileMetadata, "file") {
+			return
+		}
+		c.Set("file", fileMetadata)
+		c.Next()
+	}
+}
+
+// AuthorizeFolder is AuthorizeFile for the folder_id URL parameter. The
+// folder is stored under the context key "folder".
+func (m *Middleware) AuthorizeFolder(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		folderUUID, err := uuid.Parse(c.Param("folder_id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+			return
+		}
+
+		var folder models.Folder
+		result := m.db.Where("id = ?", folderUUID).First(&folder)
+		if result.Error != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
+
+		if !m.authorize(c, action, &folder, "folder") {
+			return
+		}
+		c.Set("folder", folder)
+		c.Next()
+	}
+}
+
+// authorize checks the user's access to the resource and aborts the request
+// when it is denied: 404 if the user can't see the resource at all, 403 if
+// they can see it but not perform the action.
+func (m *Middleware) authorize(c *gin.Context, action string, resource interface{}, kind string) bool {
+	user, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return false
+	}
+	userObj, ok := user.(models.User)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Invalid user object"})
+		return false
+	}
+
+	allowed, err := authz.Can(m.db, userObj, action, resource)
+	if err == nil && !allowed && action != authz.ActionView {
+		var visible bool
+		visible, err = authz.Can(m.db, userObj, authz.ActionView, resource)
+		if err == nil && visible {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You don't have permission to " + action + " this " + kind})
+			return false
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to check permissions: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return false
+	}
+	if !allowed {
+		if kind == "folder" {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		} else {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		}
+		return false
+	}
+	return m.authorizeAPIKeyFolder(c, resource, kind)
+}
+
+// authorizeAPIKeyFolder keeps requests made with a folder API key inside
+// that folder.
+func (m *Middleware) authorizeAPIKeyFolder(c *gin.Context, resource interface{}, kind string) bool {
+	apiKey, ok := currentAPIKey(c)
+	if !ok || apiKey.FolderID == nil {
+		return true
+	}
+
+	var folderID *uuid.UUID
+	switch resource := resource.(type) {
+	case *models.FileMetadata:
+		folderID = resource.FolderID
+	case *models.Folder:
+		folderID = &resource.ID
+	}
+	inside, err := authz.InFolder(m.db, folderID, *apiKey.FolderID)
+	if err != nil {
+		log.Printf("Failed to check API key folder: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return false
+	}
+	if !inside {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This API key can't access this " + kind})
+		return false
+	}
+	return true
+}
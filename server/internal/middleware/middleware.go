@@ -0,0 +1,29 @@
+// internal/middleware/middleware.go
+package middleware
+
+import (
+	"sync"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/config"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// Middleware holds what the middleware functions share: the settings, the
+// database users and permissions are loaded from, the token checks and the
+// per-user rate limiters.
+type Middleware struct {
+	config config.Config
+	db     *gorm.DB
+	tokens *tokens.Manager
+
+	// Create a map to hold the rate limiter for each user
+	limiters map[uuid.UUID]*rate.Limiter
+	mu       sync.Mutex
+}
+
+func New(cfg config.Config, db *gorm.DB, tokenManager *tokens.Manager) *Middleware {
+	return &Middleware{config: cfg, db: db, tokens: tokenManager, limiters: make(map[uuid.UUID]*rate.Limiter)}
+}
@@ -3,20 +3,13 @@ package middleware
 
 import (
 	"net/http"
-	"sync"
-	"time"
 
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"golang.org/x/time/rate"
 )
 
-// Create a map to hold the rate limiter for each user
-var userRateLimiters = make(map[uuid.UUID]*rate.Limiter)
-var mu sync.Mutex
-
-func RateLimitMiddleware() gin.HandlerFunc {
+func (m *Middleware) RateLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := c.Get("user")
 		if !exists {
@@ -34,13 +27,13 @@ func RateLimitMiddleware() gin.HandlerFunc {
 		userID := userObj.ID
 
 		// Lock the mutex to protect the map from concurrent writes
-		mu.Lock()
-		limiter, exists := userRateLimiters[userID]
+		m.mu.Lock()
+		limiter, exists := m.limiters[userID]
 		if !exists {
-			limiter = rate.NewLimiter(rate.Every(time.Minute), 500)
-			userRateLimiters[userID] = limiter
+			limiter = rate.NewLimiter(rate.Every(m.config.Limits.RateLimitRefill), m.config.Limits.RateLimitBurst)
+			m.limiters[userID] = limiter
 		}
-		mu.Unlock()
+		m.mu.Unlock()
 
 		if !limiter.Allow() {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
@@ -0,0 +1,52 @@
+// internal/models/apiKey.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// APIKeyScopeRead allows only requests that read, such as listing and
+	// downloading files
+	APIKeyScopeRead = "read"
+	// APIKeyScopeUpload allows only the upload routes
+	APIKeyScopeUpload = "upload"
+	// APIKeyScopeFull allows everything a logged in user can do, except
+	// managing sessions, API keys and the password
+	APIKeyScopeFull = "full"
+)
+
+// APIKey lets automation act as a user without a password. It can be
+// limited to a scope and to one folder and everything inside it. Only the
+// SHA-256 of the key is stored; Prefix is kept to tell keys apart.
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Name       string     `gorm:"size:100;not null"`
+	Prefix     string     `gorm:"size:16;not null"`
+	KeyHash    string     `gorm:"size:64;not null;uniqueIndex"`
+	Scope      string     `gorm:"size:20;not null"`
+	FolderID   *uuid.UUID `gorm:"type:uuid;index"`
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Creates the uuid
+func (apiKey *APIKey) BeforeCreate(tx *gorm.DB) (err error) {
+	apiKey.ID = uuid.New()
+	return
+}
+
+// ValidAPIKeyScope reports whether scope is one of the API key scopes.
+func ValidAPIKeyScope(scope string) bool {
+	switch scope {
+	case APIKeyScopeRead, APIKeyScopeUpload, APIKeyScopeFull:
+		return true
+	}
+	return false
+}
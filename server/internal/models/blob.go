@@ -0,0 +1,16 @@
+// internal/models/blob.go
+package models
+
+import "time"
+
+// Blob is a content-addressed object shared by every file and version with
+// the same SHA-256. RefCount counts those references; the object is deleted
+// when it drops to zero.
+type Blob struct {
+	Hash       string `gorm:"size:64;primaryKey"`
+	ObjectName string `gorm:"size:255;not null"`
+	Size       int64  `gorm:"not null"`
+	RefCount   int64  `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
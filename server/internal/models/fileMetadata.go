@@ -9,14 +9,22 @@ import (
 )
 
 type FileMetadata struct {
-	ID          uuid.UUID `gorm:"type:uuid;primaryKey;"`
-	FileName    string    `gorm:"size:255;not null;index"`
-	FileURL     string    `gorm:"size:255;not null"`
-	FileSize    int64     `gorm:"not null"`
-	ContentType string    `gorm:"size:100;index"`
-	UploadedAt  time.Time `gorm:"not null;index"`
-	UserID      uuid.UUID `gorm:"type:uuid;not null"`
-	ExpiresAt   *time.Time
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;"`
+	FileName    string     `gorm:"size:255;not null;index"`
+	FileURL     string     `gorm:"size:255;not null"`
+	FileSize    int64      `gorm:"not null"`
+	ContentType string     `gorm:"size:100;index"`
+	UploadedAt  time.Time  `gorm:"not null;index"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null"`
+	FolderID    *uuid.UUID `gorm:"type:uuid;index"`
+	Version     int        `gorm:"not null;default:1"`
+	ObjectName  string     `gorm:"size:255"`
+	ContentHash string     `gorm:"size:64;index"`
+	// Hex encoded digests of the content, empty when not known
+	ChecksumSHA256 string `gorm:"size:64"`
+	ChecksumCRC32C string `gorm:"size:8"`
+	ExpiresAt      *time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
 }
 
 // Creates the uuid
@@ -24,3 +32,13 @@ func (fileMetadata *FileMetadata) BeforeCreate(tx *gorm.DB) (err error) {
 	fileMetadata.ID = uuid.New()
 	return
 }
+
+// StorageKey returns the key of the file's content in the bucket: ObjectName
+// when it is recorded, otherwise the object under FileName where older files
+// were stored.
+func (fileMetadata *FileMetadata) StorageKey() string {
+	if fileMetadata.ObjectName != "" {
+		return fileMetadata.ObjectName
+	}
+	return fileMetadata.FileName
+}
@@ -0,0 +1,47 @@
+// internal/models/fileVersion.go
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FileVersion is an earlier content of a file, kept when the file is
+// overwritten. VersionNumber matches FileMetadata.Version at the time the
+// content was current.
+type FileVersion struct {
+	ID            uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	FileID        uuid.UUID `gorm:"type:uuid;not null;index"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index"`
+	VersionNumber int       `gorm:"not null"`
+	ObjectName    string    `gorm:"size:255;not null"`
+	ContentHash   string    `gorm:"size:64"`
+	// Checksums carried over from the file when the version was current
+	ChecksumSHA256 string    `gorm:"size:64"`
+	ChecksumCRC32C string    `gorm:"size:8"`
+	FileSize       int64     `gorm:"not null"`
+	ContentType    string    `gorm:"size:100"`
+	UploadedAt     time.Time `gorm:"not null"`
+	CreatedAt      time.Time
+}
+
+// Creates the uuid
+func (fileVersion *FileVersion) BeforeCreate(tx *gorm.DB) (err error) {
+	fileVersion.ID = uuid.New()
+	return
+}
+
+// VersionObjectName returns where a version of a file is stored in the bucket.
+func VersionObjectName(fileID uuid.UUID, versionNumber int) string {
+	return ".versions/" + fileID.String() + "/" + strconv.Itoa(versionNumber)
+}
+
+// RestoredObjectName returns where content restored from an older version is
+// stored when it isn't deduplicated, so the current content stays in place
+// until the restore is saved.
+func RestoredObjectName(fileID uuid.UUID, versionNumber int) string {
+	return VersionObjectName(fileID, versionNumber) + ".restored"
+}
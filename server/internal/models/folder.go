@@ -0,0 +1,26 @@
+// internal/models/folder.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Folder is a directory in a user's file tree. Folders only exist in the
+// database, so renaming or moving one never touches S3.
+type Folder struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;"`
+	Name      string     `gorm:"size:255;not null"`
+	ParentID  *uuid.UUID `gorm:"type:uuid;index"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Creates the uuid
+func (folder *Folder) BeforeCreate(tx *gorm.DB) (err error) {
+	folder.ID = uuid.New()
+	return
+}
@@ -0,0 +1,36 @@
+// internal/models/outboxEvent.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxFailed  = "failed"
+)
+
+// OutboxEvent is a side effect outside Postgres, such as deleting an object
+// or dropping cache entries. It is written in the same transaction as the
+// change that needs it and run by the outbox worker until it succeeds.
+// Events are deleted once they have run.
+type OutboxEvent struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Kind        string    `gorm:"size:50;not null"`
+	Payload     string    `gorm:"type:text;not null"`
+	Status      string    `gorm:"size:20;not null;index"`
+	Attempts    int       `gorm:"not null;default:0"`
+	LastError   string    `gorm:"size:1024"`
+	AvailableAt time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Creates the uuid
+func (outboxEvent *OutboxEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	outboxEvent.ID = uuid.New()
+	return
+}
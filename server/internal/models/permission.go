@@ -0,0 +1,50 @@
+// internal/models/permission.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+
+	ResourceFile   = "file"
+	ResourceFolder = "folder"
+)
+
+// Permission grants a user a role on another user's file or folder. A role
+// on a folder applies to everything inside it.
+type Permission struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_permission_grant"`
+	ResourceType string    `gorm:"size:20;not null;uniqueIndex:idx_permission_grant"`
+	ResourceID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_permission_grant;index"`
+	Role         string    `gorm:"size:20;not null"`
+	GrantedBy    uuid.UUID `gorm:"type:uuid;not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Creates the uuid
+func (permission *Permission) BeforeCreate(tx *gorm.DB) (err error) {
+	permission.ID = uuid.New()
+	return
+}
+
+// RoleRank orders roles from least to most privileged. Unknown roles rank 0.
+func RoleRank(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleOwner:
+		return 3
+	}
+	return 0
+}
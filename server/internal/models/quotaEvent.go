@@ -0,0 +1,26 @@
+// internal/models/quotaEvent.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// QuotaEvent records that a user's storage passed a warning threshold, in
+// percent of their quota.
+type QuotaEvent struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	Threshold  int       `gorm:"not null"`
+	UsedBytes  int64     `gorm:"not null"`
+	QuotaBytes int64     `gorm:"not null"`
+	CreatedAt  time.Time
+}
+
+// Creates the uuid
+func (quotaEvent *QuotaEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	quotaEvent.ID = uuid.New()
+	return
+}
@@ -0,0 +1,30 @@
+// internal/models/refreshToken.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshToken is one link in a login session's chain of refresh tokens.
+// Every refresh revokes the token it used and points ReplacedByID at its
+// successor, so a revoked token that is presented again has been reused.
+// Only the SHA-256 of the token is stored.
+type RefreshToken struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index"`
+	SessionID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	TokenHash    string     `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt    time.Time  `gorm:"not null;index"`
+	RevokedAt    *time.Time `gorm:"index"`
+	ReplacedByID *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt    time.Time
+}
+
+// Creates the uuid
+func (refreshToken *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	refreshToken.ID = uuid.New()
+	return
+}
@@ -0,0 +1,29 @@
+// internal/models/session.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is one login on one device. It lasts as long as its refresh
+// tokens keep being used, and revoking it logs that device out.
+// LastSeenAt is written in batches, so it can lag behind by a minute.
+type Session struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	UserAgent  string    `gorm:"size:512"`
+	IPAddress  string    `gorm:"size:64"`
+	LastSeenAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null;index"`
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// Creates the uuid
+func (session *Session) BeforeCreate(tx *gorm.DB) (err error) {
+	session.ID = uuid.New()
+	return
+}
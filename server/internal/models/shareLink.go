@@ -0,0 +1,64 @@
+// internal/models/shareLink.go
+package models
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ShareLink is a public link to a file, resolved through GET /s/:token.
+// Links expire and can be revoked without touching the file itself. A link
+// may also require a password, allow a limited number of downloads
+// (MaxDownloads 0 means no limit) and only accept some client networks.
+type ShareLink struct {
+	ID            uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Token         string    `gorm:"size:64;not null;uniqueIndex"`
+	FileID        uuid.UUID `gorm:"type:uuid;not null;index"`
+	CreatedBy     uuid.UUID `gorm:"type:uuid;not null;index"`
+	ExpiresAt     time.Time `gorm:"not null;index"`
+	Revoked       bool      `gorm:"not null;default:false"`
+	RevokedAt     *time.Time
+	PasswordHash  string `gorm:"size:100"`
+	MaxDownloads  int64  `gorm:"not null;default:0"`
+	DownloadCount int64  `gorm:"not null;default:0"`
+	AllowedCIDRs  string `gorm:"size:1024"`
+	CreatedAt     time.Time
+}
+
+// Creates the uuid
+func (shareLink *ShareLink) BeforeCreate(tx *gorm.DB) (err error) {
+	shareLink.ID = uuid.New()
+	return
+}
+
+// CIDRs returns the networks the link is limited to, or nil if it accepts
+// every address.
+func (shareLink *ShareLink) CIDRs() []string {
+	if shareLink.AllowedCIDRs == "" {
+		return nil
+	}
+	return strings.Split(shareLink.AllowedCIDRs, ",")
+}
+
+// AllowsIP reports whether a client at ip may use the link.
+func (shareLink *ShareLink) AllowsIP(ip string) bool {
+	cidrs := shareLink.CIDRs()
+	if cidrs == nil {
+		return true
+	}
+	clientIP := net.ParseIP(ip)
+	if clientIP == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil && network.Contains(clientIP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// internal/models/tusUpload.go
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TusUpload is the server side state of a tus 1.0 upload. Bytes are written
+// to an S3 multipart upload in PartSize parts; the bytes received past the
+// last full part are kept in a temporary tail object until the next PATCH.
+// Status takes the same values as UploadSession.Status.
+type TusUpload struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index"`
+	FolderID     *uuid.UUID `gorm:"type:uuid"`
+	FileName     string     `gorm:"size:255;not null;index"`
+	ObjectName   string     `gorm:"size:255"`
+	ContentType  string     `gorm:"size:100"`
+	Metadata     string     `gorm:"size:2048"`
+	UploadLength int64      `gorm:"not null"`
+	UploadOffset int64      `gorm:"not null"`
+	PartSize     int64      `gorm:"not null"`
+	S3UploadID   string     `gorm:"size:255;not null"`
+	Status       string     `gorm:"size:20;not null;index"`
+	// HashState is the checksum state over the bytes in the uploaded parts
+	HashState []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// Creates the uuid
+func (tusUpload *TusUpload) BeforeCreate(tx *gorm.DB) (err error) {
+	tusUpload.ID = uuid.New()
+	return
+}
+
+// StorageKey returns the key the parts are uploaded to. Uploads started
+// before ObjectName was recorded went to the key under FileName.
+func (tusUpload *TusUpload) StorageKey() string {
+	if tusUpload.ObjectName != "" {
+		return tusUpload.ObjectName
+	}
+	return tusUpload.FileName
+}
+
+// TailObjectName returns the temporary object holding the bytes between the
+// last full part and the given offset.
+func (tusUpload *TusUpload) TailObjectName(offset int64) string {
+	return ".tus/" + tusUpload.ID.String() + "/tail-" + strconv.FormatInt(offset, 10)
+}
+
+// TailLength returns how many bytes at the given offset live in the tail object.
+func (tusUpload *TusUpload) TailLength(offset int64) int64 {
+	return offset % tusUpload.PartSize
+}
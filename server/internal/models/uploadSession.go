@@ -0,0 +1,65 @@
+// internal/models/uploadSession.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	UploadSessionActive    = "active"
+	UploadSessionCompleted = "completed"
+	UploadSessionAborted   = "aborted"
+)
+
+// UploadSession tracks a resumable upload backed by an S3 multipart upload.
+// The received parts themselves are read back from S3, so a session survives
+// client crashes and server restarts.
+type UploadSession struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index"`
+	FolderID    *uuid.UUID `gorm:"type:uuid"`
+	FileName    string     `gorm:"size:255;not null;index"`
+	ObjectName  string     `gorm:"size:255"`
+	ContentType string     `gorm:"size:100"`
+	FileSize    int64      `gorm:"not null"`
+	PartSize    int64      `gorm:"not null"`
+	S3UploadID  string     `gorm:"size:255;not null"`
+	Status      string     `gorm:"size:20;not null;index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+// Creates the uuid
+func (uploadSession *UploadSession) BeforeCreate(tx *gorm.DB) (err error) {
+	uploadSession.ID = uuid.New()
+	return
+}
+
+// StorageKey returns the key the parts are uploaded to. Sessions started
+// before ObjectName was recorded went to the key under FileName.
+func (uploadSession *UploadSession) StorageKey() string {
+	if uploadSession.ObjectName != "" {
+		return uploadSession.ObjectName
+	}
+	return uploadSession.FileName
+}
+
+// TotalParts returns the number of parts the client has to send.
+func (uploadSession *UploadSession) TotalParts() int {
+	if uploadSession.FileSize == 0 {
+		return 1
+	}
+	return int((uploadSession.FileSize + uploadSession.PartSize - 1) / uploadSession.PartSize)
+}
+
+// PartLength returns the expected size of the given part.
+func (uploadSession *UploadSession) PartLength(partNumber int) int64 {
+	if partNumber < uploadSession.TotalParts() {
+		return uploadSession.PartSize
+	}
+	return uploadSession.FileSize - int64(uploadSession.TotalParts()-1)*uploadSession.PartSize
+}
@@ -0,0 +1,27 @@
+// internal/models/userIdentity.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an OpenID Connect provider,
+// identified by the provider's issuer and the account's subject. Email is
+// what the provider reported when the link was made.
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Issuer    string    `gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject"`
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject"`
+	Email     string    `gorm:"size:255"`
+	CreatedAt time.Time
+}
+
+// Creates the uuid
+func (identity *UserIdentity) BeforeCreate(tx *gorm.DB) (err error) {
+	identity.ID = uuid.New()
+	return
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type User struct {
-	ID       uuid.UUID `gorm:"type:uuid;primaryKey;"`
-	Email    string    `gorm:"uniqueIndex;not null"`
-	Password string    `gorm:"not null"`
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Email      string    `gorm:"uniqueIndex;not null"`
+	Password   string    `gorm:"not null"`
+	QuotaBytes *int64    // overrides config.Limits.DefaultQuotaMB when set
 }
 
 // Creates the uuid for the user
@@ -0,0 +1,252 @@
+// internal/oidc/oidc.go
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrInvalidIDToken means the provider's ID token failed verification
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	// ErrExchangeFailed means the provider refused the authorization code
+	ErrExchangeFailed = errors.New("authorization code exchange failed")
+)
+
+// Provider logs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE. The provider's endpoints are
+// discovered on first use, and its signing keys are fetched again whenever
+// an ID token is signed with a key that isn't known yet.
+type Provider struct {
+	config config.OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+// discovery is the part of the provider's discovery document that is used.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func New(cfg config.OIDCConfig) *Provider {
+	return &Provider{config: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// AuthRequest is the start of a login. The state, nonce and verifier have
+// to be kept until the provider redirects back to the callback.
+type AuthRequest struct {
+	URL      string
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// Identity is the user the provider vouches for in its ID token.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// idTokenClaims is the payload of an ID token.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string      `json:"nonce"`
+	AuthorizedParty string      `json:"azp"`
+	Email           string      `json:"email"`
+	EmailVerified   interface{} `json:"email_verified"`
+}
+
+// AuthCodeURL starts a login and returns where to send the browser.
+func (p *Provider) AuthCodeURL(ctx context.Context) (AuthRequest, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return AuthRequest{}, err
+	}
+
+	request := AuthRequest{}
+	for _, value := range []*string{&request.State, &request.Nonce, &request.Verifier} {
+		if *value, err = randomString(); err != nil {
+			return AuthRequest{}, err
+		}
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {request.State},
+		"nonce":                 {request.Nonce},
+		"code_challenge":        {codeChallenge(request.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	request.URL = d.AuthorizationEndpoint + separator + query.Encode()
+	return request, nil
+}
+
+// Exchange trades the authorization code from the callback for an ID token
+// and verifies it: signature, issuer, audience, expiry and nonce.
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("error creating token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("error calling token endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Identity{}, fmt.Errorf("error reading token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("%w: status %d: %s", ErrExchangeFailed, resp.StatusCode, body)
+	}
+
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil || tokenResponse.IDToken == "" {
+		return Identity{}, fmt.Errorf("%w: no ID token in the response", ErrExchangeFailed)
+	}
+	return p.verify(ctx, d, tokenResponse.IDToken, nonce)
+}
+
+func (p *Provider) verify(ctx context.Context, d *discovery, idToken string, nonce string) (Identity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, d, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return Identity{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	// A token issued to several clients must name this one as the party
+	// it was issued for
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return Identity{}, fmt.Errorf("%w: issued for another client", ErrInvalidIDToken)
+	}
+
+	return Identity{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         strings.TrimSpace(claims.Email),
+		EmailVerified: claims.EmailVerified == true || claims.EmailVerified == "true",
+	}, nil
+}
+
+// discover fetches the discovery document once and keeps it.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	issuer := strings.TrimSuffix(p.config.Issuer, "/")
+	var d discovery
+	if err := p.getJSON(ctx, issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("error loading OIDC discovery document: %v", err)
+	}
+	// The issuer in the document has to be the one configured, or tokens
+	// from another issuer could be accepted
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC discovery document is for issuer %q, expected %q", d.Issuer, p.config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing an endpoint")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, value interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(value)
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating random string: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// codeChallenge is the S256 PKCE challenge for a verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
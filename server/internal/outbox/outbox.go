@@ -0,0 +1,257 @@
+// internal/outbox/outbox.go
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	KindDeleteObject         = "delete_object"
+	KindInvalidateFileCaches = "invalidate_file_caches"
+
+	pollInterval = 10 * time.Second
+	batchSize    = 100
+	// How long a worker owns an event it picked up before another may retry it
+	claimTimeout = 5 * time.Minute
+	maxAttempts  = 10
+	maxBackoff   = time.Hour
+)
+
+// Handler runs one event. It has to be idempotent: an event runs again if
+// the worker stops before recording that it succeeded.
+type Handler func(ctx context.Context, payload []byte) error
+
+// Outbox runs the events recorded with Enqueue once their transaction has
+// committed.
+type Outbox struct {
+	db       *gorm.DB
+	cache    *redis.Client
+	storage  storage.Backend
+	handlers map[string]Handler
+	wake     chan struct{}
+}
+
+func New(db *gorm.DB, cache *redis.Client, store storage.Backend) *Outbox {
+	o := &Outbox{
+		db:       db,
+		cache:    cache,
+		storage:  store,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+	}
+	o.Register(KindDeleteObject, o.deleteObject)
+	o.Register(KindInvalidateFileCaches, o.invalidateFileCaches)
+	return o
+}
+
+// Register adds the handler for a kind of event. It has to be called before
+// the worker starts.
+func (o *Outbox) Register(kind string, handler Handler) {
+	o.handlers[kind] = handler
+}
+
+// Enqueue records an event in the transaction tx. It only runs once the
+// transaction commits.
+func Enqueue(tx *gorm.DB, kind string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding outbox payload: %v", err)
+	}
+	event := models.OutboxEvent{
+		Kind:        kind,
+		Payload:     string(data),
+		Status:      models.OutboxPending,
+		AvailableAt: time.Now(),
+	}
+	if result := tx.Create(&event); result.Error != nil {
+		return fmt.Errorf("error saving outbox event: %v", result.Error)
+	}
+	return nil
+}
+
+type deleteObjectPayload struct {
+	ObjectName string `json:"object_name"`
+}
+
+// DeleteObject enqueues the removal of an object from the bucket.
+func DeleteObject(tx *gorm.DB, objectName string) error {
+	return Enqueue(tx, KindDeleteObject, deleteObjectPayload{ObjectName: objectName})
+}
+
+type invalidateFileCachesPayload struct {
+	UserID uuid.UUID `json:"user_id"`
+	FileID uuid.UUID `json:"file_id"`
+}
+
+// InvalidateFileCaches enqueues dropping the user's file listing and search
+// results and the file's shared link cache entries.
+func InvalidateFileCaches(tx *gorm.DB, userID uuid.UUID, fileID uuid.UUID) error {
+	return Enqueue(tx, KindInvalidateFileCaches, invalidateFileCachesPayload{UserID: userID, FileID: fileID})
+}
+
+// Notify wakes the worker so events committed by a request run right away
+// instead of at the next poll.
+func (o *Outbox) Notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// StartWorker runs due events until ctx is done.
+func (o *Outbox) StartWorker(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		o.Process(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// Process runs the events that are due. Each event is claimed by moving its
+// AvailableAt forward, so several server instances can share the table.
+func (o *Outbox) Process(ctx context.Context) {
+	var events []models.OutboxEvent
+	result := o.db.Where("status = ? AND available_at <= ?", models.OutboxPending, time.Now()).Order("created_at").Limit(batchSize).Find(&events)
+	if result.Error != nil {
+		log.Printf("Failed to find outbox events: %v", result.Error)
+		return
+	}
+
+	for _, event := range events {
+		now := time.Now()
+		result := o.db.Model(&models.OutboxEvent{}).
+			Where("id = ? AND status = ? AND available_at <= ?", event.ID, models.OutboxPending, now).
+			Update("available_at", now.Add(claimTimeout))
+		if result.Error != nil {
+			log.Printf("Failed to claim outbox event: %v", result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := o.run(ctx, &event); err != nil {
+			o.retry(&event, err)
+			continue
+		}
+		if result := o.db.Delete(&event); result.Error != nil {
+			log.Printf("Failed to delete outbox event: %v", result.Error)
+		}
+	}
+}
+
+func (o *Outbox) run(ctx context.Context, event *models.OutboxEvent) error {
+	handler, ok := o.handlers[event.Kind]
+	if !ok {
+		return fmt.Errorf("unknown outbox event kind %q", event.Kind)
+	}
+	return handler(ctx, []byte(event.Payload))
+}
+
+// retry schedules the event again with exponential backoff, or marks it
+// failed after maxAttempts so it can be looked at by hand.
+func (o *Outbox) retry(event *models.OutboxEvent, err error) {
+	attempts := event.Attempts + 1
+	status := models.OutboxPending
+	if attempts >= maxAttempts {
+		status = models.OutboxFailed
+		log.Printf("Outbox event %s (%s) failed for good: %v", event.ID, event.Kind, err)
+	} else {
+		log.Printf("Outbox event %s (%s) failed: %v", event.ID, event.Kind, err)
+	}
+
+	backoff := time.Duration(1<<uint(attempts)) * time.Second
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	lastError := err.Error()
+	if len(lastError) > 1024 {
+		lastError = lastError[:1024]
+	}
+	result := o.db.Model(event).Updates(map[string]interface{}{
+		"status":       status,
+		"attempts":     attempts,
+		"last_error":   lastError,
+		"available_at": time.Now().Add(backoff),
+	})
+	if result.Error != nil {
+		log.Printf("Failed to update outbox event: %v", result.Error)
+	}
+}
+
+func (o *Outbox) deleteObject(ctx context.Context, payload []byte) error {
+	var data deleteObjectPayload
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return err
+	}
+
+	// Older files are stored under their name, so an upload with the same
+	// name may have taken the key over since the delete was enqueued
+	referenced, err := o.objectReferenced(data.ObjectName)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		log.Printf("Keeping object %s, a file or version refers to it", data.ObjectName)
+		return nil
+	}
+
+	// Removing a missing object succeeds, so this can safely run twice
+	return o.storage.Delete(ctx, data.ObjectName)
+}
+
+// objectReferenced reports whether a file, including one in the trash, or a
+// version is stored under objectName.
+func (o *Outbox) objectReferenced(objectName string) (bool, error) {
+	var count int64
+	result := o.db.Unscoped().Model(&models.FileMetadata{}).
+		Where("object_name = ? OR ((object_name IS NULL OR object_name = '') AND file_name = ?)", objectName, objectName).
+		Count(&count)
+	if result.Error != nil {
+		return false, fmt.Errorf("error checking files: %v", result.Error)
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	result = o.db.Model(&models.FileVersion{}).Where("object_name = ?", objectName).Count(&count)
+	if result.Error != nil {
+		return false, fmt.Errorf("error checking versions: %v", result.Error)
+	}
+	return count > 0, nil
+}
+
+func (o *Outbox) invalidateFileCaches(ctx context.Context, payload []byte) error {
+	var data invalidateFileCachesPayload
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return err
+	}
+
+	keys := []string{"files:" + data.UserID.String(), "shared_link:" + data.FileID.String()}
+	iter := o.cache.Scan(ctx, 0, "search_results:"+data.UserID.String()+":*", 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return o.cache.Del(ctx, keys...).Err()
+}
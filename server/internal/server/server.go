@@ -0,0 +1,256 @@
+// internal/server/server.go
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/authz"
+	"github.com/ayushh2k/go-store-s3/server/internal/blobs"
+	"github.com/ayushh2k/go-store-s3/server/internal/config"
+	"github.com/ayushh2k/go-store-s3/server/internal/handlers"
+	"github.com/ayushh2k/go-store-s3/server/internal/middleware"
+	"github.com/ayushh2k/go-store-s3/server/internal/oidc"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
+	"github.com/ayushh2k/go-store-s3/server/internal/workers"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Deps are the connections a server runs on. The database has to be
+// migrated already.
+type Deps struct {
+	DB      *gorm.DB
+	Cache   *redis.Client
+	Storage storage.Backend
+}
+
+// Server is one instance of the file API. Several can run in one process
+// as long as each has its own database, cache and bucket.
+type Server struct {
+	config     config.Config
+	handler    *handlers.Handler
+	middleware *middleware.Middleware
+	outbox     *outbox.Outbox
+	workers    *workers.Workers
+	tokens     *tokens.Manager
+	engine     *gin.Engine
+}
+
+// New wires up a server on deps. Background work does not start until
+// Start is called. Start from config.Default when not loading the
+// configuration with config.Load.
+func New(cfg config.Config, deps Deps) *Server {
+	cfg.Server.Prefix = "/" + strings.Trim(cfg.Server.Prefix, "/")
+	if cfg.Server.Prefix == "/" {
+		cfg.Server.Prefix = ""
+	}
+
+	box := outbox.New(deps.DB, deps.Cache, deps.Storage)
+	blobManager := blobs.New(deps.DB, deps.Cache, deps.Storage, box)
+	jobs := workers.New(cfg, deps.DB, deps.Cache, deps.Storage, box)
+	tokenManager := tokens.New(cfg.Auth, deps.DB, deps.Cache)
+	var oidcProvider *oidc.Provider
+	if cfg.Auth.OIDC.Issuer != "" {
+		oidcProvider = oidc.New(cfg.Auth.OIDC)
+	}
+
+	s := &Server{
+		config:     cfg,
+		handler:    handlers.New(cfg, deps.DB, deps.Cache, deps.Storage, blobManager, box, jobs, tokenManager, oidcProvider),
+		middleware: middleware.New(cfg, deps.DB, tokenManager),
+		outbox:     box,
+		workers:    jobs,
+		tokens:     tokenManager,
+	}
+
+	s.engine = gin.Default()
+	s.engine.Use(corsMiddleware())
+	s.Mount(s.engine)
+	return s
+}
+
+// Handler returns the server's own Gin engine, with CORS handling and the
+// routes under the configured prefix.
+func (s *Server) Handler() http.Handler {
+	return s.engine
+}
+
+// Start runs the background workers, the outbox worker and the session
+// activity worker until ctx is done.
+func (s *Server) Start(ctx context.Context) {
+	s.workers.Start(ctx)
+	go s.outbox.StartWorker(ctx)
+	go s.tokens.StartActivityWorker(ctx)
+}
+
+// Run starts the workers and serves HTTP on the configured address until
+// ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	s.Start(ctx)
+	httpServer := &http.Server{
+		Addr:              s.config.Server.Addr,
+		Handler:           s.engine,
+		ReadHeaderTimeout: s.config.Timeouts.ReadHeader,
+		IdleTimeout:       s.config.Timeouts.Idle,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down server: %v", err)
+		}
+	}()
+
+	log.Printf("Listening on %s", s.config.Server.Addr)
+	err := httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Mount adds the routes under the configured prefix to router, which lets
+// the API be served from another Gin engine. CORS is left to that engine.
+func (s *Server) Mount(router gin.IRouter) {
+	h, m := s.handler, s.middleware
+	r := router.Group(s.config.Server.Prefix)
+
+	// Authentication routes
+	r.POST("/register", h.Signup)
+	r.POST("/login", h.Login)
+	r.POST("/refresh", h.Refresh)
+	r.GET("/auth/oidc/login", h.OIDCLogin)
+	r.GET("/auth/oidc/callback", h.OIDCCallback)
+	r.POST("/auth/oidc/link", h.OIDCLink)
+	r.POST("/logout", m.AuthMiddleware, m.RateLimitMiddleware(), h.Logout)
+	r.POST("/logout/all", m.AuthMiddleware, m.RateLimitMiddleware(), h.LogoutAll)
+
+	// Public share links
+	r.GET("/s/:token", h.ResolveShareLink)
+	r.POST("/s/:token", h.ResolveShareLink)
+
+	// File routes
+	r.POST("/upload", m.AuthMiddleware, m.RateLimitMiddleware(), h.UploadFile)                                                //upload
+	r.GET("/files", m.AuthMiddleware, m.RateLimitMiddleware(), h.GetFiles)                                                    //get all files
+	r.GET("/share/:file_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionShare), h.ShareFile)      //share file
+	r.DELETE("/files/:file_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionDelete), h.DeleteFile) //delete file
+	r.PUT("/files/:file_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionEdit), h.UpdateFileInfo)  //update file info
+
+	// Share links and file expiry
+	r.POST("/files/:file_id/shares", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionShare), h.ShareFile)
+	r.GET("/files/:file_id/shares", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionShare), h.ListShareLinks)
+	r.DELETE("/shares/:share_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.RevokeShareLink)
+	r.PUT("/files/:file_id/expiry", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionDelete), h.SetFileExpiry)
+
+	// Sharing with other users
+	r.GET("/shared-with-me", m.AuthMiddleware, m.RateLimitMiddleware(), h.SharedWithMe)
+	r.GET("/shared-with-me/folders/:folder_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFolder(authz.ActionView), h.ListSharedFolder)
+	r.POST("/files/:file_id/permissions", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionShare), h.GrantFilePermission)
+	r.GET("/files/:file_id/permissions", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionShare), h.ListFilePermissions)
+	r.DELETE("/files/:file_id/permissions/:permission_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionShare), h.RevokeFilePermission)
+	r.POST("/folders/:folder_id/permissions", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFolder(authz.ActionShare), h.GrantFolderPermission)
+	r.GET("/folders/:folder_id/permissions", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFolder(authz.ActionShare), h.ListFolderPermissions)
+	r.DELETE("/folders/:folder_id/permissions/:permission_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFolder(authz.ActionShare), h.RevokeFolderPermission)
+
+	// Folders
+	r.POST("/folders", m.AuthMiddleware, m.RateLimitMiddleware(), h.CreateFolder)
+	r.GET("/folders", m.AuthMiddleware, m.RateLimitMiddleware(), h.ListFolders)
+	r.PUT("/folders/:folder_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFolder(authz.ActionEdit), h.RenameFolder)
+	r.POST("/folders/:folder_id/move", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFolder(authz.ActionEdit), h.MoveFolder)
+	r.DELETE("/folders/:folder_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFolder(authz.ActionDelete), h.DeleteFolder)
+	r.POST("/files/:file_id/move", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionEdit), h.MoveFile)
+
+	// Trash
+	r.GET("/trash", m.AuthMiddleware, m.RateLimitMiddleware(), h.GetTrash)
+	r.POST("/trash/:file_id/restore", m.AuthMiddleware, m.RateLimitMiddleware(), h.RestoreFile)
+	r.DELETE("/trash/:file_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.PurgeTrashedFile)
+	r.DELETE("/trash", m.AuthMiddleware, m.RateLimitMiddleware(), h.EmptyTrash)
+
+	// File downloads
+	r.GET("/files/:file_id/verify", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionView), h.VerifyFile)
+	r.GET("/files/:file_id/content", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionView), h.DownloadFile)
+	r.POST("/files/archive", m.AuthMiddleware, m.RateLimitMiddleware(), h.DownloadArchive)
+
+	// File versions
+	r.GET("/files/:file_id/versions", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionView), h.ListFileVersions)
+	r.GET("/files/:file_id/versions/:version_id/content", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionView), h.DownloadFileVersion)
+	r.POST("/files/:file_id/versions/:version_id/restore", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionEdit), h.RestoreFileVersion)
+	r.DELETE("/files/:file_id/versions/:version_id", m.AuthMiddleware, m.RateLimitMiddleware(), m.AuthorizeFile(authz.ActionDelete), h.DeleteFileVersion)
+
+	// Direct-to-S3 uploads
+	r.POST("/upload/presign", m.AuthMiddleware, m.RateLimitMiddleware(), h.PresignUpload)
+	r.POST("/upload/confirm", m.AuthMiddleware, m.RateLimitMiddleware(), h.ConfirmUpload)
+
+	// Resumable upload sessions
+	r.POST("/uploads", m.AuthMiddleware, m.RateLimitMiddleware(), h.CreateUploadSession)
+	r.GET("/uploads/:session_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.GetUploadSession)
+	r.PUT("/uploads/:session_id/parts/:part_number", m.AuthMiddleware, m.RateLimitMiddleware(), h.UploadPart)
+	r.POST("/uploads/:session_id/complete", m.AuthMiddleware, m.RateLimitMiddleware(), h.CompleteUploadSession)
+	r.DELETE("/uploads/:session_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.AbortUploadSession)
+
+	// tus resumable upload protocol
+	tus := r.Group("/tus")
+	tus.OPTIONS("/", h.TusOptions)
+	tus.POST("/", m.AuthMiddleware, m.RateLimitMiddleware(), h.TusCreate)
+	tus.HEAD("/:upload_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.TusHead)
+	tus.PATCH("/:upload_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.TusPatch)
+	tus.DELETE("/:upload_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.TusDelete)
+
+	r.GET("/search", m.AuthMiddleware, m.RateLimitMiddleware(), h.SearchFiles)
+
+	r.GET("/user/email", m.AuthMiddleware, m.RateLimitMiddleware(), h.GetUserEmail)
+	r.GET("/user/total-files", m.AuthMiddleware, m.RateLimitMiddleware(), h.GetTotalFiles)
+	r.GET("/user/storage-used", m.AuthMiddleware, m.RateLimitMiddleware(), h.GetStorageUsed)
+	r.GET("/user/quota", m.AuthMiddleware, m.RateLimitMiddleware(), h.GetQuota)
+	r.GET("/sessions", m.AuthMiddleware, m.RateLimitMiddleware(), h.ListSessions)
+	r.DELETE("/sessions/:session_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.RevokeSession)
+	r.PUT("/user/password", m.AuthMiddleware, m.RateLimitMiddleware(), h.ChangePassword)
+
+	// API keys for automation
+	r.POST("/api-keys", m.AuthMiddleware, m.RateLimitMiddleware(), h.CreateAPIKey)
+	r.GET("/api-keys", m.AuthMiddleware, m.RateLimitMiddleware(), h.ListAPIKeys)
+	r.DELETE("/api-keys/:key_id", m.AuthMiddleware, m.RateLimitMiddleware(), h.RevokeAPIKey)
+
+	// Admin routes
+	r.POST("/admin/reconcile", m.AuthMiddleware, m.RateLimitMiddleware(), m.AdminMiddleware, h.ReconcileStorage)
+	r.POST("/admin/files/:file_id/checksums", m.AuthMiddleware, m.RateLimitMiddleware(), m.AdminMiddleware, h.RecordFileChecksums)
+
+	r.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "https://github.com/ayushh2k/go-store-s3",
+			"docs":    "https://documenter.getpostman.com/view/25648449/2sAXqp83yv",
+		})
+	})
+
+	// Websockets
+	// var hub *ws.Hub
+	// r.GET("/ws", m.AuthMiddleware, func(c *gin.Context) {
+	// 	handlers.ServeWs(hub, c)
+	// })
+}
+
+func corsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, Upload-Checksum, Upload-Defer-Length, Range, If-Range, If-None-Match, If-Modified-Since, X-Share-Password, Content-Digest, X-Checksum-SHA256")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Location, Content-Disposition, Content-Range, Accept-Ranges, ETag, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Tus-Checksum-Algorithm, Upload-Offset, Upload-Length, Upload-Metadata, Upload-Expires, Repr-Digest, X-Checksum-SHA256")
+
+		// Only answer CORS preflights here; plain OPTIONS requests (tus discovery) reach the router
+		if c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,391 @@
+// internal/storage/local.go
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LocalBackend stores objects as files under a root directory, for running
+// without S3. Object bodies live under objects/, their content types under
+// meta/ and unfinished multipart uploads under multipart/.
+type LocalBackend struct {
+	root string
+}
+
+func NewLocalBackend(root string) *LocalBackend {
+	return &LocalBackend{root: root}
+}
+
+type localMeta struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+}
+
+// cleanKey rejects keys that would escape the root directory.
+func cleanKey(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if key == "" || cleaned != key {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return cleaned, nil
+}
+
+func (backend *LocalBackend) objectPath(key string) string {
+	return filepath.Join(backend.root, "objects", filepath.FromSlash(key))
+}
+
+func (backend *LocalBackend) metaPath(key string) string {
+	return filepath.Join(backend.root, "meta", filepath.FromSlash(key))
+}
+
+func (backend *LocalBackend) uploadPath(uploadID string) string {
+	return filepath.Join(backend.root, "multipart", uploadID)
+}
+
+func (backend *LocalBackend) EnsureBucket(ctx context.Context) error {
+	for _, dir := range []string{"objects", "meta", "multipart", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(backend.root, dir), 0o755); err != nil {
+			return fmt.Errorf("error creating storage directory: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeFile writes the reader to a temporary file and renames it into place,
+// so readers never see a partial object. It returns the size and MD5.
+func (backend *LocalBackend) writeFile(target string, reader io.Reader) (int64, string, error) {
+	if err := backend.EnsureBucket(context.Background()); err != nil {
+		return 0, "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(backend.root, "tmp"), "upload-")
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return 0, "", err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (backend *LocalBackend) writeMeta(key string, contentType string) error {
+	data, err := json.Marshal(localMeta{Key: key, ContentType: contentType})
+	if err != nil {
+		return err
+	}
+	target := backend.metaPath(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0o644)
+}
+
+func (backend *LocalBackend) Put(ctx context.Context, key string, reader io.Reader, size int64, opts PutOptions) (ObjectInfo, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	written, etag, err := backend.writeFile(backend.objectPath(key), reader)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("error writing object: %v", err)
+	}
+	if size >= 0 && written != size {
+		os.Remove(backend.objectPath(key))
+		return ObjectInfo{}, fmt.Errorf("object is %d bytes, expected %d", written, size)
+	}
+	if err := backend.writeMeta(key, opts.ContentType); err != nil {
+		return ObjectInfo{}, fmt.Errorf("error writing object metadata: %v", err)
+	}
+	return ObjectInfo{Key: key, Size: written, ETag: etag, ContentType: opts.ContentType, LastModified: time.Now()}, nil
+}
+
+func (backend *LocalBackend) Get(ctx context.Context, key string, opts GetOptions) (io.ReadCloser, ObjectInfo, error) {
+	info, err := backend.Stat(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	file, err := os.Open(backend.objectPath(info.Key))
+	if err != nil {
+		return nil, ObjectInfo{}, localError(err)
+	}
+	if _, err := file.Seek(opts.Offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+	if opts.Length > 0 {
+		return readCloser{Reader: io.LimitReader(file, opts.Length), Closer: file}, info, nil
+	}
+	return file, info, nil
+}
+
+func (backend *LocalBackend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	stat, err := os.Stat(backend.objectPath(key))
+	if err != nil {
+		return ObjectInfo{}, localError(err)
+	}
+	if stat.IsDir() {
+		return ObjectInfo{}, ErrNotFound
+	}
+
+	info := ObjectInfo{Key: key, Size: stat.Size(), LastModified: stat.ModTime()}
+	if data, err := os.ReadFile(backend.metaPath(key)); err == nil {
+		var meta localMeta
+		if json.Unmarshal(data, &meta) == nil {
+			info.ContentType = meta.ContentType
+		}
+	}
+	// Stands in for an ETag without hashing the file on every stat
+	info.ETag = strconv.FormatInt(stat.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(stat.Size(), 16)
+	return info, nil
+}
+
+func (backend *LocalBackend) Copy(ctx context.Context, srcKey string, dstKey string) (ObjectInfo, error) {
+	dstKey, err := cleanKey(dstKey)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	reader, info, err := backend.Get(ctx, srcKey, GetOptions{})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer reader.Close()
+	return backend.Put(ctx, dstKey, reader, info.Size, PutOptions{ContentType: info.ContentType})
+}
+
+func (backend *LocalBackend) Delete(ctx context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(backend.objectPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(backend.metaPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (backend *LocalBackend) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	objectsDir := filepath.Join(backend.root, "objects")
+	err := filepath.WalkDir(objectsDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		relative, err := filepath.Rel(objectsDir, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := backend.Stat(ctx, key)
+		if err != nil {
+			return err
+		}
+		return fn(info)
+	})
+	return err
+}
+
+func (backend *LocalBackend) PresignPut(ctx context.Context, key string, expiry time.Duration, headers http.Header) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (backend *LocalBackend) PresignPost(ctx context.Context, key string, expiry time.Duration, contentType string, size int64) (string, map[string]string, error) {
+	return "", nil, ErrNotSupported
+}
+
+func (backend *LocalBackend) CreateMultipartUpload(ctx context.Context, key string, opts PutOptions) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	uploadID := uuid.New().String()
+	dir := backend.uploadPath(uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating multipart upload: %v", err)
+	}
+	data, err := json.Marshal(localMeta{Key: key, ContentType: opts.ContentType})
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "upload.json"), data, 0o644); err != nil {
+		return "", fmt.Errorf("error creating multipart upload: %v", err)
+	}
+	return uploadID, nil
+}
+
+// loadUpload checks that the upload exists and belongs to key.
+func (backend *LocalBackend) loadUpload(key string, uploadID string) (localMeta, error) {
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return localMeta{}, ErrUploadNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(backend.uploadPath(uploadID), "upload.json"))
+	if err != nil {
+		return localMeta{}, ErrUploadNotFound
+	}
+	var meta localMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.Key != key {
+		return localMeta{}, ErrUploadNotFound
+	}
+	return meta, nil
+}
+
+func (backend *LocalBackend) UploadPart(ctx context.Context, key string, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	if _, err := backend.loadUpload(key, uploadID); err != nil {
+		return Part{}, err
+	}
+	target := filepath.Join(backend.uploadPath(uploadID), "part-"+strconv.Itoa(partNumber))
+	written, etag, err := backend.writeFile(target, io.LimitReader(reader, size))
+	if err != nil {
+		return Part{}, fmt.Errorf("error writing part: %v", err)
+	}
+	if written != size {
+		os.Remove(target)
+		return Part{}, fmt.Errorf("part is %d bytes, expected %d", written, size)
+	}
+	if err := os.WriteFile(target+".etag", []byte(etag), 0o644); err != nil {
+		return Part{}, fmt.Errorf("error writing part: %v", err)
+	}
+	return Part{PartNumber: partNumber, Size: written, ETag: etag}, nil
+}
+
+func (backend *LocalBackend) ListParts(ctx context.Context, key string, uploadID string) ([]Part, error) {
+	if _, err := backend.loadUpload(key, uploadID); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(backend.uploadPath(uploadID))
+	if err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	var parts []Part
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "part-") || strings.HasSuffix(name, ".etag") {
+			continue
+		}
+		partNumber, err := strconv.Atoi(strings.TrimPrefix(name, "part-"))
+		if err != nil {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		etag, err := os.ReadFile(filepath.Join(backend.uploadPath(uploadID), name+".etag"))
+		if err != nil {
+			// The part is still being written
+			continue
+		}
+		parts = append(parts, Part{PartNumber: partNumber, Size: stat.Size(), ETag: string(etag)})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (backend *LocalBackend) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part, opts PutOptions) (ObjectInfo, error) {
+	received, err := backend.ListParts(ctx, key, uploadID)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	byNumber := make(map[int]Part, len(received))
+	for _, part := range received {
+		byNumber[part.PartNumber] = part
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, part := range parts {
+		stored, ok := byNumber[part.PartNumber]
+		if !ok || stored.ETag != part.ETag {
+			return ObjectInfo{}, fmt.Errorf("part %d was not uploaded", part.PartNumber)
+		}
+		file, err := os.Open(filepath.Join(backend.uploadPath(uploadID), "part-"+strconv.Itoa(part.PartNumber)))
+		if err != nil {
+			return ObjectInfo{}, err
+		}
+		files = append(files, file)
+		readers = append(readers, file)
+	}
+
+	info, err := backend.Put(ctx, key, io.MultiReader(readers...), -1, opts)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	os.RemoveAll(backend.uploadPath(uploadID))
+	return info, nil
+}
+
+func (backend *LocalBackend) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	if _, err := backend.loadUpload(key, uploadID); err != nil {
+		return err
+	}
+	return os.RemoveAll(backend.uploadPath(uploadID))
+}
+
+func (backend *LocalBackend) URL(key string) string {
+	return "file://" + filepath.ToSlash(backend.objectPath(key))
+}
+
+func localError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,256 @@
+// internal/storage/memory.go
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryBackend keeps objects in memory. Everything is lost on restart, so
+// it is meant for tests and trying the server out.
+type MemoryBackend struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+	uploads map[string]*memoryUpload
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+type memoryUpload struct {
+	key         string
+	contentType string
+	parts       map[int]memoryObject
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		objects: make(map[string]memoryObject),
+		uploads: make(map[string]*memoryUpload),
+	}
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readAll reads exactly size bytes, or everything when size is negative.
+func readAll(reader io.Reader, size int64) ([]byte, error) {
+	if size < 0 {
+		return io.ReadAll(reader)
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != size {
+		return nil, fmt.Errorf("object is %d bytes, expected %d", len(data), size)
+	}
+	return data, nil
+}
+
+func (backend *MemoryBackend) EnsureBucket(ctx context.Context) error {
+	return nil
+}
+
+func (backend *MemoryBackend) Put(ctx context.Context, key string, reader io.Reader, size int64, opts PutOptions) (ObjectInfo, error) {
+	// Keys are checked like on the filesystem, so both backends accept the same ones
+	key, err := cleanKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	data, err := readAll(reader, size)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return backend.store(key, data, opts.ContentType), nil
+}
+
+func (backend *MemoryBackend) store(key string, data []byte, contentType string) ObjectInfo {
+	info := ObjectInfo{
+		Key:          key,
+		Size:         int64(len(data)),
+		ETag:         md5Hex(data),
+		ContentType:  contentType,
+		LastModified: time.Now(),
+	}
+	backend.mu.Lock()
+	backend.objects[key] = memoryObject{data: data, info: info}
+	backend.mu.Unlock()
+	return info
+}
+
+func (backend *MemoryBackend) Get(ctx context.Context, key string, opts GetOptions) (io.ReadCloser, ObjectInfo, error) {
+	backend.mu.RLock()
+	object, ok := backend.objects[key]
+	backend.mu.RUnlock()
+	if !ok {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+
+	data := object.data
+	if opts.Offset > int64(len(data)) {
+		data = nil
+	} else {
+		data = data[opts.Offset:]
+	}
+	if opts.Length > 0 && opts.Length < int64(len(data)) {
+		data = data[:opts.Length]
+	}
+	return io.NopCloser(bytes.NewReader(data)), object.info, nil
+}
+
+func (backend *MemoryBackend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	backend.mu.RLock()
+	defer backend.mu.RUnlock()
+	object, ok := backend.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return object.info, nil
+}
+
+func (backend *MemoryBackend) Copy(ctx context.Context, srcKey string, dstKey string) (ObjectInfo, error) {
+	dstKey, err := cleanKey(dstKey)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	backend.mu.RLock()
+	object, ok := backend.objects[srcKey]
+	backend.mu.RUnlock()
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+	// Stored slices are never written to, so they can be shared
+	return backend.store(dstKey, object.data, object.info.ContentType), nil
+}
+
+func (backend *MemoryBackend) Delete(ctx context.Context, key string) error {
+	backend.mu.Lock()
+	delete(backend.objects, key)
+	backend.mu.Unlock()
+	return nil
+}
+
+func (backend *MemoryBackend) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	backend.mu.RLock()
+	var infos []ObjectInfo
+	for key, object := range backend.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, object.info)
+		}
+	}
+	backend.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (backend *MemoryBackend) PresignPut(ctx context.Context, key string, expiry time.Duration, headers http.Header) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (backend *MemoryBackend) PresignPost(ctx context.Context, key string, expiry time.Duration, contentType string, size int64) (string, map[string]string, error) {
+	return "", nil, ErrNotSupported
+}
+
+func (backend *MemoryBackend) CreateMultipartUpload(ctx context.Context, key string, opts PutOptions) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	uploadID := uuid.New().String()
+	backend.mu.Lock()
+	backend.uploads[uploadID] = &memoryUpload{key: key, contentType: opts.ContentType, parts: make(map[int]memoryObject)}
+	backend.mu.Unlock()
+	return uploadID, nil
+}
+
+func (backend *MemoryBackend) UploadPart(ctx context.Context, key string, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	data, err := readAll(reader, size)
+	if err != nil {
+		return Part{}, err
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	upload, ok := backend.uploads[uploadID]
+	if !ok || upload.key != key {
+		return Part{}, ErrUploadNotFound
+	}
+	part := Part{PartNumber: partNumber, Size: int64(len(data)), ETag: md5Hex(data)}
+	upload.parts[partNumber] = memoryObject{data: data, info: ObjectInfo{Size: part.Size, ETag: part.ETag}}
+	return part, nil
+}
+
+func (backend *MemoryBackend) ListParts(ctx context.Context, key string, uploadID string) ([]Part, error) {
+	backend.mu.RLock()
+	defer backend.mu.RUnlock()
+	upload, ok := backend.uploads[uploadID]
+	if !ok || upload.key != key {
+		return nil, ErrUploadNotFound
+	}
+
+	parts := make([]Part, 0, len(upload.parts))
+	for partNumber, part := range upload.parts {
+		parts = append(parts, Part{PartNumber: partNumber, Size: part.info.Size, ETag: part.info.ETag})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (backend *MemoryBackend) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part, opts PutOptions) (ObjectInfo, error) {
+	backend.mu.Lock()
+	upload, ok := backend.uploads[uploadID]
+	if !ok || upload.key != key {
+		backend.mu.Unlock()
+		return ObjectInfo{}, ErrUploadNotFound
+	}
+	var buffer bytes.Buffer
+	for _, part := range parts {
+		stored, ok := upload.parts[part.PartNumber]
+		if !ok || stored.info.ETag != part.ETag {
+			backend.mu.Unlock()
+			return ObjectInfo{}, fmt.Errorf("part %d was not uploaded", part.PartNumber)
+		}
+		buffer.Write(stored.data)
+	}
+	delete(backend.uploads, uploadID)
+	backend.mu.Unlock()
+
+	return backend.store(key, buffer.Bytes(), opts.ContentType), nil
+}
+
+func (backend *MemoryBackend) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	upload, ok := backend.uploads[uploadID]
+	if !ok || upload.key != key {
+		return ErrUploadNotFound
+	}
+	delete(backend.uploads, uploadID)
+	return nil
+}
+
+func (backend *MemoryBackend) URL(key string) string {
+	return "memory://" + key
+}
@@ -0,0 +1,215 @@
+// internal/storage/minio.go
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// MinioBackend stores objects in an S3 compatible bucket.
+type MinioBackend struct {
+	client *minio.Client
+	core   minio.Core
+	bucket string
+	region string
+}
+
+func NewMinioBackend(client *minio.Client, bucket string, region string) *MinioBackend {
+	return &MinioBackend{client: client, core: minio.Core{Client: client}, bucket: bucket, region: region}
+}
+
+// minioError maps S3 error codes to the package's errors.
+func minioError(err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey":
+		return ErrNotFound
+	case "NoSuchUpload":
+		return ErrUploadNotFound
+	}
+	return err
+}
+
+func minioObjectInfo(info minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}
+}
+
+func (backend *MinioBackend) EnsureBucket(ctx context.Context) error {
+	exists, err := backend.client.BucketExists(ctx, backend.bucket)
+	if err != nil {
+		return fmt.Errorf("error checking bucket existence: %v", err)
+	}
+	if !exists {
+		err = backend.client.MakeBucket(ctx, backend.bucket, minio.MakeBucketOptions{Region: backend.region})
+		if err != nil {
+			return fmt.Errorf("error creating bucket: %v", err)
+		}
+	}
+	return nil
+}
+
+func (backend *MinioBackend) Put(ctx context.Context, key string, reader io.Reader, size int64, opts PutOptions) (ObjectInfo, error) {
+	info, err := backend.client.PutObject(ctx, backend.bucket, key, reader, size, minio.PutObjectOptions{
+		ContentType: opts.ContentType,
+	})
+	if err != nil {
+		return ObjectInfo{}, minioError(err)
+	}
+	return ObjectInfo{Key: key, Size: info.Size, ETag: info.ETag, ContentType: opts.ContentType, LastModified: info.LastModified}, nil
+}
+
+func (backend *MinioBackend) Get(ctx context.Context, key string, opts GetOptions) (io.ReadCloser, ObjectInfo, error) {
+	getOptions := minio.GetObjectOptions{}
+	if opts.Offset > 0 || opts.Length > 0 {
+		end := int64(0)
+		if opts.Length > 0 {
+			end = opts.Offset + opts.Length - 1
+		}
+		if err := getOptions.SetRange(opts.Offset, end); err != nil {
+			return nil, ObjectInfo{}, err
+		}
+	}
+
+	object, err := backend.client.GetObject(ctx, backend.bucket, key, getOptions)
+	if err != nil {
+		return nil, ObjectInfo{}, minioError(err)
+	}
+	// The request is only sent on the first read or stat
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, ObjectInfo{}, minioError(err)
+	}
+	return object, minioObjectInfo(info), nil
+}
+
+func (backend *MinioBackend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := backend.client.StatObject(ctx, backend.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, minioError(err)
+	}
+	return minioObjectInfo(info), nil
+}
+
+// Copy uses ComposeObject, which also copies objects above the 5GB limit of
+// a single server side copy.
+func (backend *MinioBackend) Copy(ctx context.Context, srcKey string, dstKey string) (ObjectInfo, error) {
+	info, err := backend.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: backend.bucket, Object: dstKey},
+		minio.CopySrcOptions{Bucket: backend.bucket, Object: srcKey},
+	)
+	if err != nil {
+		return ObjectInfo{}, minioError(err)
+	}
+	return ObjectInfo{Key: dstKey, Size: info.Size, ETag: info.ETag, LastModified: info.LastModified}, nil
+}
+
+func (backend *MinioBackend) Delete(ctx context.Context, key string) error {
+	return minioError(backend.client.RemoveObject(ctx, backend.bucket, key, minio.RemoveObjectOptions{}))
+}
+
+func (backend *MinioBackend) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	for object := range backend.client.ListObjects(ctx, backend.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return minioError(object.Err)
+		}
+		if err := fn(minioObjectInfo(object)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (backend *MinioBackend) PresignPut(ctx context.Context, key string, expiry time.Duration, headers http.Header) (string, error) {
+	presignedURL, err := backend.client.PresignHeader(ctx, http.MethodPut, backend.bucket, key, expiry, nil, headers)
+	if err != nil {
+		return "", err
+	}
+	return presignedURL.String(), nil
+}
+
+func (backend *MinioBackend) PresignPost(ctx context.Context, key string, expiry time.Duration, contentType string, size int64) (string, map[string]string, error) {
+	policy := minio.NewPostPolicy()
+	policy.SetBucket(backend.bucket)
+	policy.SetKey(key)
+	policy.SetExpires(time.Now().Add(expiry))
+	policy.SetContentType(contentType)
+	policy.SetContentLengthRange(size, size)
+
+	presignedURL, formData, err := backend.client.PresignedPostPolicy(ctx, policy)
+	if err != nil {
+		return "", nil, err
+	}
+	return presignedURL.String(), formData, nil
+}
+
+func (backend *MinioBackend) CreateMultipartUpload(ctx context.Context, key string, opts PutOptions) (string, error) {
+	uploadID, err := backend.core.NewMultipartUpload(ctx, backend.bucket, key, minio.PutObjectOptions{
+		ContentType: opts.ContentType,
+	})
+	if err != nil {
+		return "", minioError(err)
+	}
+	return uploadID, nil
+}
+
+func (backend *MinioBackend) UploadPart(ctx context.Context, key string, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	part, err := backend.core.PutObjectPart(ctx, backend.bucket, key, uploadID, partNumber, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return Part{}, minioError(err)
+	}
+	return Part{PartNumber: part.PartNumber, Size: part.Size, ETag: part.ETag}, nil
+}
+
+func (backend *MinioBackend) ListParts(ctx context.Context, key string, uploadID string) ([]Part, error) {
+	var parts []Part
+	marker := 0
+	for {
+		result, err := backend.core.ListObjectParts(ctx, backend.bucket, key, uploadID, marker, 1000)
+		if err != nil {
+			return nil, minioError(err)
+		}
+		for _, part := range result.ObjectParts {
+			parts = append(parts, Part{PartNumber: part.PartNumber, Size: part.Size, ETag: part.ETag})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextPartNumberMarker
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (backend *MinioBackend) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part, opts PutOptions) (ObjectInfo, error) {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	info, err := backend.core.CompleteMultipartUpload(ctx, backend.bucket, key, uploadID, completeParts, minio.PutObjectOptions{
+		ContentType: opts.ContentType,
+	})
+	if err != nil {
+		return ObjectInfo{}, minioError(err)
+	}
+	return ObjectInfo{Key: key, Size: info.Size, ETag: info.ETag, ContentType: opts.ContentType, LastModified: info.LastModified}, nil
+}
+
+func (backend *MinioBackend) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	return minioError(backend.core.AbortMultipartUpload(ctx, backend.bucket, key, uploadID))
+}
+
+func (backend *MinioBackend) URL(key string) string {
+	return fmt.Sprintf("%s/%s/%s", backend.client.EndpointURL().String(), backend.bucket, key)
+}
@@ -0,0 +1,143 @@
+// internal/storage/storage.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for an object that doesn't exist.
+	ErrNotFound = errors.New("object not found")
+	// ErrUploadNotFound is returned for a multipart upload that doesn't exist
+	// or was already completed or aborted.
+	ErrUploadNotFound = errors.New("multipart upload not found")
+	// ErrNotSupported is returned by backends without a feature, such as
+	// presigned URLs on the local filesystem.
+	ErrNotSupported = errors.New("not supported by this storage backend")
+)
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+}
+
+// Part is one uploaded part of a multipart upload.
+type Part struct {
+	PartNumber int
+	Size       int64
+	ETag       string
+}
+
+type PutOptions struct {
+	ContentType string
+}
+
+// GetOptions selects a byte range. A zero Length reads to the end.
+type GetOptions struct {
+	Offset int64
+	Length int64
+}
+
+// Backend stores objects by key. Keys are slash separated paths relative to
+// the backend's bucket or root directory.
+type Backend interface {
+	// EnsureBucket creates the bucket or directory objects live in.
+	EnsureBucket(ctx context.Context) error
+
+	Put(ctx context.Context, key string, reader io.Reader, size int64, opts PutOptions) (ObjectInfo, error)
+	// Get returns the requested range of the object and the object's info.
+	Get(ctx context.Context, key string, opts GetOptions) (io.ReadCloser, ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Copy(ctx context.Context, srcKey string, dstKey string) (ObjectInfo, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every object whose key starts with prefix.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+
+	// PresignPut returns a URL the client can PUT the object to. The given
+	// headers are part of the signature.
+	PresignPut(ctx context.Context, key string, expiry time.Duration, headers http.Header) (string, error)
+	// PresignPost returns a URL and form fields for a browser upload of
+	// exactly size bytes of the given content type.
+	PresignPost(ctx context.Context, key string, expiry time.Duration, contentType string, size int64) (string, map[string]string, error)
+
+	CreateMultipartUpload(ctx context.Context, key string, opts PutOptions) (string, error)
+	UploadPart(ctx context.Context, key string, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error)
+	// ListParts returns the parts received so far, by part number.
+	ListParts(ctx context.Context, key string, uploadID string) ([]Part, error)
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part, opts PutOptions) (ObjectInfo, error)
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+
+	// URL returns where the object lives, for display.
+	URL(key string) string
+}
+
+// NewReadSeeker returns a reader over the object that fetches only the
+// bytes it is asked for, so http.ServeContent can answer range requests
+// without downloading the whole object.
+func NewReadSeeker(ctx context.Context, backend Backend, info ObjectInfo) io.ReadSeekCloser {
+	return &rangeReader{ctx: ctx, backend: backend, info: info}
+}
+
+type rangeReader struct {
+	ctx     context.Context
+	backend Backend
+	info    ObjectInfo
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (reader *rangeReader) Read(p []byte) (int, error) {
+	if reader.offset >= reader.info.Size {
+		return 0, io.EOF
+	}
+	if reader.body == nil {
+		body, _, err := reader.backend.Get(reader.ctx, reader.info.Key, GetOptions{Offset: reader.offset})
+		if err != nil {
+			return 0, err
+		}
+		reader.body = body
+	}
+	n, err := reader.body.Read(p)
+	reader.offset += int64(n)
+	return n, err
+}
+
+func (reader *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	var position int64
+	switch whence {
+	case io.SeekStart:
+		position = offset
+	case io.SeekCurrent:
+		position = reader.offset + offset
+	case io.SeekEnd:
+		position = reader.info.Size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if position < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+	if position != reader.offset && reader.body != nil {
+		reader.body.Close()
+		reader.body = nil
+	}
+	reader.offset = position
+	return position, nil
+}
+
+func (reader *rangeReader) Close() error {
+	if reader.body != nil {
+		return reader.body.Close()
+	}
+	return nil
+}
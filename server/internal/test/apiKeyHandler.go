@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/authz"
+	"github.com/ayushh2k/go-store-s3/server/internal/middleware"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAPIKeyScopes sends routes of every kind with a key of each scope,
+// and with a key limited to a folder.
+func TestAPIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenManager := tokens.New(testConfig.Auth, testDB, testCache(t))
+
+	user := models.User{Email: "apikey-owner@example.com", Password: "x"}
+	testDB.Create(&user)
+	folder := models.Folder{Name: "apikey-test", UserID: user.ID}
+	testDB.Create(&folder)
+	inside := models.FileMetadata{
+		FileName:   user.ID.String() + "/apikey-inside.txt",
+		FileURL:    "apikey-inside",
+		FileSize:   1,
+		UploadedAt: time.Now(),
+		UserID:     user.ID,
+		FolderID:   &folder.ID,
+	}
+	testDB.Create(&inside)
+	outside := models.FileMetadata{
+		FileName:   user.ID.String() + "/apikey-outside.txt",
+		FileURL:    "apikey-outside",
+		FileSize:   1,
+		UploadedAt: time.Now(),
+		UserID:     user.ID,
+	}
+	testDB.Create(&outside)
+
+	keys := map[string]string{}
+	newKey := func(name string, apiKey models.APIKey) {
+		apiKey.UserID = user.ID
+		apiKey.Name = name
+		key, err := tokenManager.CreateAPIKey(&apiKey)
+		if err != nil {
+			t.Fatalf("Failed to create API key: %v", err)
+		}
+		keys[name] = key
+	}
+	newKey("read", models.APIKey{Scope: models.APIKeyScopeRead})
+	newKey("upload", models.APIKey{Scope: models.APIKeyScopeUpload})
+	newKey("full", models.APIKey{Scope: models.APIKeyScopeFull})
+	newKey("folder", models.APIKey{Scope: models.APIKeyScopeFull, FolderID: &folder.ID})
+	expired := time.Now().Add(-time.Minute)
+	newKey("expired", models.APIKey{Scope: models.APIKeyScopeFull, ExpiresAt: &expired})
+	newKey("revoked", models.APIKey{Scope: models.APIKeyScopeFull})
+	var revoked models.APIKey
+	testDB.Where("user_id = ? AND name = ?", user.ID, "revoked").First(&revoked)
+	tokenManager.RevokeAPIKey(user.ID, revoked.ID)
+
+	defer func() {
+		testDB.Where("user_id = ?", user.ID).Delete(&models.APIKey{})
+		testDB.Unscoped().Delete(&inside)
+		testDB.Unscoped().Delete(&outside)
+		testDB.Delete(&folder)
+		testDB.Delete(&user)
+	}()
+
+	// Expected status per key for each route
+	routes := []struct {
+		method string
+		path   string
+		want   map[string]int
+	}{
+		{"GET", "/files", map[string]int{"read": 200, "upload": 403, "full": 200, "folder": 200}},
+		{"GET", "/files/:file_id/content", map[string]int{"read": 200, "upload": 403, "full": 200, "folder": 200}},
+		{"POST", "/files/archive", map[string]int{"read": 200, "upload": 403, "full": 200, "folder": 403}},
+		{"GET", "/search", map[string]int{"read": 200, "upload": 403, "full": 200, "folder": 403}},
+		{"GET", "/share/:file_id", map[string]int{"read": 403, "upload": 403, "full": 200, "folder": 200}},
+		{"POST", "/files/:file_id/shares", map[string]int{"read": 403, "upload": 403, "full": 200, "folder": 200}},
+		{"DELETE", "/files/:file_id", map[string]int{"read": 403, "upload": 403, "full": 200, "folder": 200}},
+		{"POST", "/folders", map[string]int{"read": 403, "upload": 403, "full": 200, "folder": 200}},
+		{"POST", "/upload", map[string]int{"read": 403, "upload": 200, "full": 200, "folder": 200}},
+		{"PUT", "/uploads/:session_id/parts/:part_number", map[string]int{"read": 403, "upload": 200, "full": 200, "folder": 200}},
+		{"PATCH", "/tus/:upload_id", map[string]int{"read": 403, "upload": 200, "full": 200, "folder": 200}},
+		{"POST", "/trash/:file_id/restore", map[string]int{"read": 403, "upload": 403, "full": 200, "folder": 403}},
+		// Only a logged in user may manage sessions, keys and the password
+		{"GET", "/sessions", map[string]int{"read": 403, "upload": 403, "full": 403, "folder": 403}},
+		{"POST", "/logout", map[string]int{"read": 403, "upload": 403, "full": 403, "folder": 403}},
+		{"PUT", "/user/password", map[string]int{"read": 403, "upload": 403, "full": 403, "folder": 403}},
+		{"GET", "/api-keys", map[string]int{"read": 403, "upload": 403, "full": 403, "folder": 403}},
+		{"POST", "/api-keys", map[string]int{"read": 403, "upload": 403, "full": 403, "folder": 403}},
+		{"POST", "/admin/reconcile", map[string]int{"read": 403, "upload": 403, "full": 403, "folder": 403}},
+	}
+
+	r := gin.New()
+	m := middleware.New(testConfig, testDB, tokenManager)
+	allowed := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+	for _, route := range routes {
+		r.Handle(route.method, route.path, m.AuthMiddleware, allowed)
+	}
+
+	replacer := strings.NewReplacer(
+		":file_id", inside.ID.String(),
+		":session_id", inside.ID.String(),
+		":part_number", "1",
+		":upload_id", inside.ID.String(),
+	)
+	for _, route := range routes {
+		path := replacer.Replace(route.path)
+		for name, want := range route.want {
+			t.Run(route.method+" "+route.path+" with "+name+" key", func(t *testing.T) {
+				req, _ := http.NewRequest(route.method, path, nil)
+				req.Header.Set("X-API-Key", keys[name])
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				assert.Equal(t, want, w.Code)
+			})
+		}
+	}
+
+	t.Run("Bearer API key", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/files", nil)
+		req.Header.Set("Authorization", "Bearer "+keys["read"])
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	for _, name := range []string{"expired", "revoked"} {
+		t.Run(name+" key", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/files", nil)
+			req.Header.Set("X-API-Key", keys[name])
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+
+	t.Run("Unknown key", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/files", nil)
+		req.Header.Set("X-API-Key", tokens.APIKeyPrefix+"unknown")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	// A folder key only reaches files inside its folder
+	folderRoutes := gin.New()
+	folderRoutes.GET("/files/:file_id/content", m.AuthMiddleware, m.AuthorizeFile(authz.ActionView), allowed)
+	for name, test := range map[string]struct {
+		file models.FileMetadata
+		want int
+	}{
+		"File inside the key's folder":  {inside, http.StatusOK},
+		"File outside the key's folder": {outside, http.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/files/"+test.file.ID.String()+"/content", nil)
+			req.Header.Set("X-API-Key", keys["folder"])
+			w := httptest.NewRecorder()
+			folderRoutes.ServeHTTP(w, req)
+
+			assert.Equal(t, test.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/authz"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/server"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role   string
+		action string
+		want   bool
+	}{
+		{"", authz.ActionView, false},
+		{models.RoleViewer, authz.ActionView, true},
+		{models.RoleViewer, authz.ActionEdit, false},
+		{models.RoleViewer, authz.ActionDelete, false},
+		{models.RoleViewer, authz.ActionShare, false},
+		{models.RoleEditor, authz.ActionView, true},
+		{models.RoleEditor, authz.ActionEdit, true},
+		{models.RoleEditor, authz.ActionDelete, true},
+		{models.RoleEditor, authz.ActionShare, false},
+		{models.RoleOwner, authz.ActionShare, true},
+		{models.RoleOwner, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+"/"+tt.action, func(t *testing.T) {
+			assert.Equal(t, tt.want, authz.RoleAllows(tt.role, tt.action))
+		})
+	}
+}
+
+// TestAuthorizeRoutes sends every route of the server that takes a file or
+// folder ID to the owner, a viewer of the file, an editor of its folder and
+// a stranger. The routes are mounted by the server itself, so a new route
+// fails the test until its expected action is listed here.
+func TestAuthorizeRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache := testCache(t)
+	srv := server.New(testConfig, server.Deps{DB: testDB, Cache: cache, Storage: storage.NewMemoryBackend()})
+	tokenManager := tokens.New(testConfig.Auth, testDB, cache)
+
+	users := map[string]*models.User{}
+	accessTokens := map[string]string{}
+	for _, name := range []string{"owner", "viewer", "editor", "stranger"} {
+		user := models.User{Email: "authz-" + name + "@example.com", Password: "x"}
+		testDB.Create(&user)
+		users[name] = &user
+		pair, err := tokenManager.Issue(user.ID, tokens.Client{})
+		if err != nil {
+			t.Fatalf("Failed to issue tokens: %v", err)
+		}
+		accessTokens[name] = pair.AccessToken
+	}
+	owner := users["owner"]
+	defer func() {
+		for _, user := range users {
+			testDB.Where("user_id = ?", user.ID).Delete(&models.Session{})
+			testDB.Delete(user)
+		}
+	}()
+
+	// fixture creates a file in a folder, shared with the viewer and the
+	// editor. Each request gets its own, as the handlers behind the checks
+	// run too.
+	type fixture struct {
+		file        models.FileMetadata
+		folder      models.Folder
+		permissions []models.Permission
+	}
+	newFixture := func() fixture {
+		folder := models.Folder{Name: "authz-test", UserID: owner.ID}
+		testDB.Create(&folder)
+		file := models.FileMetadata{
+			FileName:   owner.ID.String() + "/authz-test.txt",
+			FileURL:    "authz-test",
+			FileSize:   1,
+			UploadedAt: time.Now(),
+			UserID:     owner.ID,
+			FolderID:   &folder.ID,
+		}
+		testDB.Create(&file)
+		permissions := []models.Permission{
+			{UserID: users["viewer"].ID, ResourceType: models.ResourceFile, ResourceID: file.ID, Role: models.RoleViewer, GrantedBy: owner.ID},
+			{UserID: users["editor"].ID, ResourceType: models.ResourceFolder, ResourceID: folder.ID, Role: models.RoleEditor, GrantedBy: owner.ID},
+		}
+		for i := range permissions {
+			testDB.Create(&permissions[i])
+		}
+		return fixture{file: file, folder: folder, permissions: permissions}
+	}
+	removeFixture := func(f fixture) {
+		testDB.Where("resource_id IN ?", []interface{}{f.file.ID, f.folder.ID}).Delete(&models.Permission{})
+		testDB.Where("file_id = ?", f.file.ID).Delete(&models.ShareLink{})
+		testDB.Unscoped().Where("user_id = ?", owner.ID).Delete(&models.FileMetadata{})
+		testDB.Unscoped().Where("user_id = ?", owner.ID).Delete(&models.Folder{})
+	}
+
+	// Expected status per user for each action; 200 means the request got
+	// past the authorization check, whatever the handler answered
+	fileExpectations := map[string]map[string]int{
+		authz.ActionView:   {"owner": 200, "viewer": 200, "editor": 200, "stranger": 404},
+		authz.ActionEdit:   {"owner": 200, "viewer": 403, "editor": 200, "stranger": 404},
+		authz.ActionDelete: {"owner": 200, "viewer": 403, "editor": 200, "stranger": 404},
+		authz.ActionShare:  {"owner": 200, "viewer": 403, "editor": 403, "stranger": 404},
+	}
+	folderExpectations := map[string]map[string]int{
+		authz.ActionView:   {"owner": 200, "viewer": 404, "editor": 200, "stranger": 404},
+		authz.ActionEdit:   {"owner": 200, "viewer": 404, "editor": 200, "stranger": 404},
+		authz.ActionDelete: {"owner": 200, "viewer": 404, "editor": 200, "stranger": 404},
+		authz.ActionShare:  {"owner": 200, "viewer": 404, "editor": 403, "stranger": 404},
+	}
+
+	// The action each route has to be authorized for. Routes mapped to ""
+	// check access themselves.
+	actions := map[string]string{
+		"GET /share/:file_id":                                   authz.ActionShare,
+		"DELETE /files/:file_id":                                authz.ActionDelete,
+		"PUT /files/:file_id":                                   authz.ActionEdit,
+		"POST /files/:file_id/shares":                           authz.ActionShare,
+		"GET /files/:file_id/shares":                            authz.ActionShare,
+		"PUT /files/:file_id/expiry":                            authz.ActionDelete,
+		"POST /files/:file_id/permissions":                      authz.ActionShare,
+		"GET /files/:file_id/permissions":                       authz.ActionShare,
+		"DELETE /files/:file_id/permissions/:permission_id":     authz.ActionShare,
+		"POST /files/:file_id/move":                             authz.ActionEdit,
+		"GET /files/:file_id/verify":                            authz.ActionView,
+		"GET /files/:file_id/content":                           authz.ActionView,
+		"GET /files/:file_id/versions":                          authz.ActionView,
+		"GET /files/:file_id/versions/:version_id/content":      authz.ActionView,
+		"POST /files/:file_id/versions/:version_id/restore":     authz.ActionEdit,
+		"DELETE /files/:file_id/versions/:version_id":           authz.ActionDelete,
+		"GET /shared-with-me/folders/:folder_id":                authz.ActionView,
+		"POST /folders/:folder_id/permissions":                  authz.ActionShare,
+		"GET /folders/:folder_id/permissions":                   authz.ActionShare,
+		"DELETE /folders/:folder_id/permissions/:permission_id": authz.ActionShare,
+		"PUT /folders/:folder_id":                               authz.ActionEdit,
+		"POST /folders/:folder_id/move":                         authz.ActionEdit,
+		"DELETE /folders/:folder_id":                            authz.ActionDelete,
+		// Trashed files are looked up among the user's own
+		"POST /trash/:file_id/restore": "",
+		"DELETE /trash/:file_id":       "",
+		// Behind AdminMiddleware
+		"POST /admin/files/:file_id/checksums": "",
+	}
+
+	// The server's routes are mounted on an engine that notes whether a
+	// request got past AuthorizeFile or AuthorizeFolder
+	var authorized bool
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		_, file := c.Get("file")
+		_, folder := c.Get("folder")
+		authorized = file || folder
+	})
+	srv.Mount(r)
+
+	send := func(method string, path string, user string) *httptest.ResponseRecorder {
+		authorized = false
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+accessTokens[user])
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	prefix := strings.TrimRight(testConfig.Server.Prefix, "/")
+	tested := 0
+	for _, route := range r.Routes() {
+		routePath := strings.TrimPrefix(route.Path, prefix)
+		if !strings.Contains(routePath, ":file_id") && !strings.Contains(routePath, ":folder_id") {
+			continue
+		}
+		action, ok := actions[route.Method+" "+routePath]
+		if !ok {
+			t.Errorf("Route %s %s takes an ID but has no expected action", route.Method, routePath)
+			continue
+		}
+		if action == "" {
+			continue
+		}
+		tested++
+
+		expectations := fileExpectations[action]
+		if strings.Contains(routePath, ":folder_id") {
+			expectations = folderExpectations[action]
+		}
+		for name, want := range expectations {
+			t.Run(route.Method+" "+routePath+" as "+name, func(t *testing.T) {
+				f := newFixture()
+				defer removeFixture(f)
+				path := strings.NewReplacer(
+					":file_id", f.file.ID.String(),
+					":folder_id", f.folder.ID.String(),
+					":permission_id", f.permissions[0].ID.String(),
+					":version_id", f.file.ID.String(),
+				).Replace(route.Path)
+
+				w := send(route.Method, path, name)
+				if want == http.StatusOK {
+					assert.True(t, authorized, "status %d: %s", w.Code, w.Body.String())
+				} else {
+					assert.False(t, authorized)
+					assert.Equal(t, want, w.Code)
+				}
+			})
+		}
+	}
+	listed := 0
+	for _, action := range actions {
+		if action != "" {
+			listed++
+		}
+	}
+	assert.Equal(t, listed, tested, "Some listed routes aren't mounted")
+
+	t.Run("Unknown file", func(t *testing.T) {
+		f := newFixture()
+		defer removeFixture(f)
+		w := send("GET", prefix+"/files/"+f.folder.ID.String()+"/content", "owner")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid file ID", func(t *testing.T) {
+		w := send("GET", prefix+"/files/not-a-uuid/content", "owner")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/blobs"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestBlobReferences uploads the same content twice and deletes the copies
+// one at a time. The blob has to outlive the first delete and go with the
+// last one.
+func TestBlobReferences(t *testing.T) {
+	ctx := context.Background()
+	cache := testCache(t)
+	store := storage.NewMemoryBackend()
+	box := outbox.New(testDB, cache, store)
+	manager := blobs.New(testDB, cache, store, box)
+
+	user := models.User{Email: "blobs-owner@example.com", Password: "x"}
+	testDB.Create(&user)
+	defer testDB.Delete(&user)
+
+	content := "shared content " + uuid.NewString()
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	objectName := blobs.ObjectName(hash)
+
+	// Each upload lands under a key of its own and is then handed to Store
+	upload := func(name string) models.FileMetadata {
+		uploadKey := ".uploads/" + uuid.NewString()
+		_, err := store.Put(ctx, uploadKey, strings.NewReader(content), int64(len(content)), storage.PutOptions{})
+		if err != nil {
+			t.Fatalf("Failed to upload: %v", err)
+		}
+
+		var file models.FileMetadata
+		blobObjectName, err := manager.Store(ctx, uploadKey, hash, int64(len(content)), func(tx *gorm.DB, blobObjectName string) error {
+			file = models.FileMetadata{
+				FileName:    user.ID.String() + "/" + name,
+				FileURL:     store.URL(blobObjectName),
+				FileSize:    int64(len(content)),
+				UploadedAt:  time.Now(),
+				UserID:      user.ID,
+				ObjectName:  blobObjectName,
+				ContentHash: hash,
+			}
+			return tx.Create(&file).Error
+		})
+		if err != nil {
+			t.Fatalf("Failed to store upload: %v", err)
+		}
+		assert.Equal(t, objectName, blobObjectName)
+
+		// The uploaded object goes once the outbox runs
+		box.Process(ctx)
+		_, err = store.Stat(ctx, uploadKey)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		return file
+	}
+	refCount := func() int64 {
+		var blob models.Blob
+		if result := testDB.Where("hash = ?", hash).Find(&blob); result.Error != nil {
+			t.Fatalf("Failed to load blob: %v", result.Error)
+		}
+		return blob.RefCount
+	}
+
+	first := upload("first.txt")
+	second := upload("second.txt")
+	defer testDB.Unscoped().Where("user_id = ?", user.ID).Delete(&models.FileMetadata{})
+
+	assert.Equal(t, int64(2), refCount())
+	assert.Equal(t, content, readObject(t, store, objectName, storage.GetOptions{}))
+
+	t.Run("Deleting one copy keeps the blob", func(t *testing.T) {
+		if err := blobs.PurgeFile(testDB, &first); err != nil {
+			t.Fatalf("Failed to purge file: %v", err)
+		}
+		box.Process(ctx)
+
+		assert.Equal(t, int64(1), refCount())
+		assert.Equal(t, content, readObject(t, store, objectName, storage.GetOptions{}))
+	})
+
+	t.Run("Deleting the last copy removes the blob", func(t *testing.T) {
+		if err := blobs.PurgeFile(testDB, &second); err != nil {
+			t.Fatalf("Failed to purge file: %v", err)
+		}
+		box.Process(ctx)
+
+		var count int64
+		testDB.Model(&models.Blob{}).Where("hash = ?", hash).Count(&count)
+		assert.Equal(t, int64(0), count)
+		_, err := store.Stat(ctx, objectName)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("A failed save doesn't count a reference", func(t *testing.T) {
+		uploadKey := ".uploads/" + uuid.NewString()
+		_, err := store.Put(ctx, uploadKey, strings.NewReader(content), int64(len(content)), storage.PutOptions{})
+		if err != nil {
+			t.Fatalf("Failed to upload: %v", err)
+		}
+
+		_, err = manager.Store(ctx, uploadKey, hash, int64(len(content)), func(tx *gorm.DB, blobObjectName string) error {
+			return gorm.ErrInvalidData
+		})
+		assert.Error(t, err)
+
+		var count int64
+		testDB.Model(&models.Blob{}).Where("hash = ?", hash).Count(&count)
+		assert.Equal(t, int64(0), count)
+		_, err = store.Stat(ctx, objectName)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStorageBackends runs the same checks against every backend that
+// doesn't need a server, so they behave alike.
+func TestStorageBackends(t *testing.T) {
+	backends := map[string]func(t *testing.T) (storage.Backend, string){
+		"memory": func(t *testing.T) (storage.Backend, string) {
+			return storage.NewMemoryBackend(), ""
+		},
+		"local": func(t *testing.T) (storage.Backend, string) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, "root")
+			backend := storage.NewLocalBackend(root)
+			if err := backend.EnsureBucket(context.Background()); err != nil {
+				t.Fatalf("Failed to create storage directories: %v", err)
+			}
+			return backend, dir
+		},
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Path escaping", func(t *testing.T) {
+				backend, dir := newBackend(t)
+				testStorageKeys(t, backend, dir)
+			})
+			t.Run("Multipart upload", func(t *testing.T) {
+				backend, _ := newBackend(t)
+				testStorageMultipart(t, backend)
+			})
+			t.Run("Range get", func(t *testing.T) {
+				backend, _ := newBackend(t)
+				testStorageRange(t, backend)
+			})
+		})
+	}
+}
+
+func testStorageKeys(t *testing.T, backend storage.Backend, dir string) {
+	ctx := context.Background()
+
+	for _, key := range []string{"", "../escape", "a/../../escape", "/absolute", "a//b", "a/./b", "a/"} {
+		_, err := backend.Put(ctx, key, strings.NewReader("x"), 1, storage.PutOptions{})
+		assert.Error(t, err, "Put %q", key)
+
+		_, err = backend.CreateMultipartUpload(ctx, key, storage.PutOptions{})
+		assert.Error(t, err, "CreateMultipartUpload %q", key)
+	}
+
+	_, err := backend.Put(ctx, "user/file.txt", strings.NewReader("x"), 1, storage.PutOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = backend.Copy(ctx, "user/file.txt", "../escape")
+	assert.Error(t, err)
+
+	_, _, err = backend.Get(ctx, "../escape", storage.GetOptions{})
+	assert.Error(t, err)
+
+	// Nothing was written next to the root directory
+	if dir != "" {
+		_, err := os.Stat(filepath.Join(dir, "escape"))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func testStorageMultipart(t *testing.T, backend storage.Backend) {
+	ctx := context.Background()
+	key := "user/multipart.bin"
+	contents := map[int]string{1: "first-", 2: "second-", 3: "third"}
+
+	uploadID, err := backend.CreateMultipartUpload(ctx, key, storage.PutOptions{ContentType: "application/octet-stream"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Parts may arrive in any order
+	uploaded := map[int]storage.Part{}
+	for _, partNumber := range []int{3, 1, 2} {
+		part, err := backend.UploadPart(ctx, key, uploadID, partNumber, strings.NewReader(contents[partNumber]), int64(len(contents[partNumber])))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		assert.Equal(t, partNumber, part.PartNumber)
+		assert.NotEmpty(t, part.ETag)
+		uploaded[partNumber] = part
+	}
+
+	parts, err := backend.ListParts(ctx, key, uploadID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(parts))
+	}
+	for i, part := range parts {
+		assert.Equal(t, i+1, part.PartNumber)
+		assert.Equal(t, uploaded[i+1].ETag, part.ETag)
+		assert.Equal(t, int64(len(contents[i+1])), part.Size)
+	}
+
+	// Uploading a part again replaces it, and the old ETag is refused
+	stale := uploaded[2]
+	replaced, err := backend.UploadPart(ctx, key, uploadID, 2, strings.NewReader("SECOND-"), 7)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert.NotEqual(t, stale.ETag, replaced.ETag)
+	_, err = backend.CompleteMultipartUpload(ctx, key, uploadID, []storage.Part{uploaded[1], stale, uploaded[3]}, storage.PutOptions{})
+	assert.Error(t, err)
+
+	// A part that was never uploaded is refused
+	_, err = backend.CompleteMultipartUpload(ctx, key, uploadID, []storage.Part{uploaded[1], replaced, uploaded[3], {PartNumber: 4, ETag: "missing"}}, storage.PutOptions{})
+	assert.Error(t, err)
+
+	// Another key can't complete the upload
+	_, err = backend.CompleteMultipartUpload(ctx, "user/other.bin", uploadID, []storage.Part{uploaded[1], replaced, uploaded[3]}, storage.PutOptions{})
+	assert.ErrorIs(t, err, storage.ErrUploadNotFound)
+
+	info, err := backend.CompleteMultipartUpload(ctx, key, uploadID, []storage.Part{uploaded[1], replaced, uploaded[3]}, storage.PutOptions{ContentType: "application/octet-stream"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert.Equal(t, int64(len("first-SECOND-third")), info.Size)
+	assert.Equal(t, "first-SECOND-third", readObject(t, backend, key, storage.GetOptions{}))
+
+	// A completed upload is gone
+	_, err = backend.ListParts(ctx, key, uploadID)
+	assert.ErrorIs(t, err, storage.ErrUploadNotFound)
+	assert.ErrorIs(t, backend.AbortMultipartUpload(ctx, key, uploadID), storage.ErrUploadNotFound)
+}
+
+func testStorageRange(t *testing.T, backend storage.Backend) {
+	ctx := context.Background()
+	key := "user/range.txt"
+	content := "0123456789"
+
+	_, err := backend.Put(ctx, key, strings.NewReader(content), int64(len(content)), storage.PutOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for name, test := range map[string]struct {
+		opts storage.GetOptions
+		want string
+	}{
+		"Whole object":        {storage.GetOptions{}, content},
+		"From an offset":      {storage.GetOptions{Offset: 4}, "456789"},
+		"Offset and length":   {storage.GetOptions{Offset: 2, Length: 3}, "234"},
+		"Length past the end": {storage.GetOptions{Offset: 8, Length: 10}, "89"},
+		"Offset at the end":   {storage.GetOptions{Offset: 10}, ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, readObject(t, backend, key, test.opts))
+		})
+	}
+
+	// The info describes the whole object, not the range
+	body, info, err := backend.Get(ctx, key, storage.GetOptions{Offset: 2, Length: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	body.Close()
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "text/plain", info.ContentType)
+
+	// Seeking through the range reader fetches the right bytes
+	reader := storage.NewReadSeeker(ctx, backend, info)
+	defer reader.Close()
+	_, err = reader.Seek(-3, io.SeekEnd)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tail, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert.Equal(t, "789", string(tail))
+
+	_, _, err = backend.Get(ctx, "user/missing.txt", storage.GetOptions{})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func readObject(t *testing.T, backend storage.Backend, key string, opts storage.GetOptions) string {
+	body, _, err := backend.Get(context.Background(), key, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer body.Close()
+	var buffer bytes.Buffer
+	_, err = io.Copy(&buffer, body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return buffer.String()
+}
@@ -9,16 +9,39 @@ import (
 	"os"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ayushh2k/go-store-s3/server/internal/config"
 	"github.com/ayushh2k/go-store-s3/server/internal/handlers"
-	"github.com/ayushh2k/go-store-s3/server/internal/initializers"
 	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/tokens"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// testDB is the database the tests run against, set up by TestMain.
+var testDB *gorm.DB
+
+// testConfig is what the handlers under test are configured with.
+var testConfig = testSettings()
+
+func testSettings() config.Config {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	return cfg
+}
+
+// testCache returns a cache that lives as long as the test.
+func testCache(t *testing.T) *redis.Client {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
 func TestMain(m *testing.M) {
 	// Set up test database
 	dsn := "host=localhost user=testuser password=testpass dbname=testdb port=5432 sslmode=disable TimeZone=Asia/Shanghai"
@@ -27,12 +50,12 @@ func TestMain(m *testing.M) {
 		panic(fmt.Sprintf("failed to connect database: %v", err))
 	}
 
-	err = db.AutoMigrate(&models.User{})
+	err = db.AutoMigrate(&models.User{}, &models.FileMetadata{}, &models.Folder{}, &models.Permission{}, &models.RefreshToken{}, &models.Session{}, &models.APIKey{}, &models.Blob{}, &models.FileVersion{}, &models.ShareLink{}, &models.OutboxEvent{}, &models.TusUpload{})
 	if err != nil {
 		panic(fmt.Sprintf("failed to migrate database: %v", err))
 	}
 
-	initializers.DB = initializers.DBinstance{Db: db}
+	testDB = db
 
 	code := m.Run()
 
@@ -48,7 +71,7 @@ func TestMain(m *testing.M) {
 func TestSignup(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
-	r.POST("/register", handlers.Signup)
+	r.POST("/register", handlers.New(testConfig, testDB, nil, nil, nil, nil, nil, nil, nil).Signup)
 
 	t.Run("Valid signup", func(t *testing.T) {
 		body := gin.H{
@@ -66,21 +89,21 @@ func TestSignup(t *testing.T) {
 		json.Unmarshal(w.Body.Bytes(), &response)
 		assert.Equal(t, "User created successfully", response["message"])
 
-		initializers.DB.Db.Where("email = ?", "test@example.com").Delete(&models.User{})
+		testDB.Where("email = ?", "test@example.com").Delete(&models.User{})
 	})
 }
 
 func TestLogin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
-	r.POST("/login", handlers.Login)
+	r.POST("/login", handlers.New(testConfig, testDB, nil, nil, nil, nil, nil, tokens.New(testConfig.Auth, testDB, nil), nil).Login)
 
 	hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), 10)
 	testUser := models.User{
 		Email:    "testlogin@example.com",
 		Password: string(hash),
 	}
-	initializers.DB.Db.Create(&testUser)
+	testDB.Create(&testUser)
 
 	t.Run("Valid login", func(t *testing.T) {
 		body := gin.H{
@@ -98,6 +121,6 @@ func TestLogin(t *testing.T) {
 		json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NotEmpty(t, response["token"])
 
-		initializers.DB.Db.Where("email = ?", "testlogin@example.com").Delete(&models.User{})
+		testDB.Where("email = ?", "testlogin@example.com").Delete(&models.User{})
 	})
 }
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/blobs"
+	"github.com/ayushh2k/go-store-s3/server/internal/handlers"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTusUpload sends whole files through tus. Uploads of the same content
+// share one blob.
+func TestTusUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	cache := testCache(t)
+	store := storage.NewMemoryBackend()
+	box := outbox.New(testDB, cache, store)
+	manager := blobs.New(testDB, cache, store, box)
+	h := handlers.New(testConfig, testDB, cache, store, manager, box, nil, nil, nil)
+
+	user := models.User{Email: "tus-owner@example.com", Password: "x"}
+	testDB.Create(&user)
+	defer func() {
+		var files []models.FileMetadata
+		testDB.Unscoped().Where("user_id = ?", user.ID).Find(&files)
+		for _, file := range files {
+			blobs.PurgeFile(testDB, &file)
+		}
+		testDB.Where("user_id = ?", user.ID).Delete(&models.TusUpload{})
+		testDB.Delete(&user)
+	}()
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user", user)
+	})
+	r.POST("/tus/", h.TusCreate)
+	r.PATCH("/tus/:upload_id", h.TusPatch)
+
+	// create starts a tus upload and returns its ID
+	create := func(name string, length int) string {
+		req, _ := http.NewRequest("POST", "/tus/", nil)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Upload-Length", strconv.Itoa(length))
+		req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(name)))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Failed to create tus upload: %d %s", w.Code, w.Body.String())
+		}
+		location := w.Header().Get("Location")
+		return location[strings.LastIndex(location, "/")+1:]
+	}
+	// patch sends the whole content in one PATCH
+	patch := func(uploadID string, content string) (models.TusUpload, *httptest.ResponseRecorder) {
+		req, _ := http.NewRequest("PATCH", "/tus/"+uploadID, strings.NewReader(content))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+		req.Header.Set("Upload-Offset", "0")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var tusUpload models.TusUpload
+		if result := testDB.Where("id = ?", uploadID).First(&tusUpload); result.Error != nil {
+			t.Fatalf("Failed to load tus upload: %v", result.Error)
+		}
+		return tusUpload, w
+	}
+
+	content := "tus content"
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+
+	t.Run("Uploads of the same content share a blob", func(t *testing.T) {
+		for _, name := range []string{"first.txt", "second.txt"} {
+			tusUpload, w := patch(create(name, len(content)), content)
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, models.UploadSessionCompleted, tusUpload.Status)
+
+			var file models.FileMetadata
+			testDB.Where("user_id = ? AND file_name = ?", user.ID, user.ID.String()+"/"+name).First(&file)
+			assert.Equal(t, hash, file.ContentHash)
+			assert.Equal(t, blobs.ObjectName(hash), file.ObjectName)
+		}
+		box.Process(ctx)
+
+		var blob models.Blob
+		testDB.Where("hash = ?", hash).First(&blob)
+		assert.Equal(t, int64(2), blob.RefCount)
+		assert.Equal(t, content, readObject(t, store, blobs.ObjectName(hash), storage.GetOptions{}))
+
+		// Nothing is left under the upload keys
+		err := store.List(ctx, ".uploads/", func(object storage.ObjectInfo) error {
+			t.Errorf("Unexpected object %s", object.Key)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("An upload whose name was taken meanwhile is aborted", func(t *testing.T) {
+		other := "other content"
+		uploadID := create("late.txt", len(other))
+		testDB.Create(&models.FileMetadata{
+			FileName:   user.ID.String() + "/late.txt",
+			FileURL:    "late",
+			FileSize:   1,
+			UploadedAt: time.Now(),
+			UserID:     user.ID,
+		})
+
+		tusUpload, w := patch(uploadID, other)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, models.UploadSessionAborted, tusUpload.Status)
+
+		// Neither the parts nor the temporary objects are left behind
+		_, err := store.ListParts(ctx, tusUpload.ObjectName, tusUpload.S3UploadID)
+		assert.ErrorIs(t, err, storage.ErrUploadNotFound)
+		for _, prefix := range []string{".uploads/", ".tus/"} {
+			err := store.List(ctx, prefix, func(object storage.ObjectInfo) error {
+				t.Errorf("Unexpected object %s", object.Key)
+				return nil
+			})
+			assert.NoError(t, err)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/handlers"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRenameFile renames files next to others of the same name. Names are
+// unique per folder, and a rename never touches the stored objects.
+func TestRenameFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache := testCache(t)
+	store := storage.NewMemoryBackend()
+	h := handlers.New(testConfig, testDB, cache, store, nil, outbox.New(testDB, cache, store), nil, nil, nil)
+
+	user := models.User{Email: "rename-owner@example.com", Password: "x"}
+	testDB.Create(&user)
+	folder := models.Folder{Name: "rename-test", UserID: user.ID}
+	testDB.Create(&folder)
+	newFile := func(name string, folderID *uuid.UUID) models.FileMetadata {
+		file := models.FileMetadata{
+			FileName:   user.ID.String() + "/" + name,
+			FileURL:    name,
+			FileSize:   1,
+			UploadedAt: time.Now(),
+			UserID:     user.ID,
+			FolderID:   folderID,
+		}
+		testDB.Create(&file)
+		return file
+	}
+	first := newFile("first.txt", nil)
+	newFile("second.txt", nil)
+	newFile("taken.txt", &folder.ID)
+	defer func() {
+		testDB.Unscoped().Where("user_id = ?", user.ID).Delete(&models.FileMetadata{})
+		testDB.Delete(&folder)
+		testDB.Delete(&user)
+	}()
+
+	r := gin.New()
+	r.PUT("/files/:file_id", func(c *gin.Context) {
+		var file models.FileMetadata
+		if result := testDB.Where("id = ?", c.Param("file_id")).First(&file); result.Error != nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.Set("file", file)
+	}, h.UpdateFileInfo)
+
+	rename := func(name string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(gin.H{"file_name": name})
+		req, _ := http.NewRequest("PUT", "/files/"+first.ID.String(), bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	load := func() models.FileMetadata {
+		var file models.FileMetadata
+		testDB.Where("id = ?", first.ID).First(&file)
+		return file
+	}
+
+	t.Run("A name taken in the same folder is refused", func(t *testing.T) {
+		w := rename("second.txt")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, first.FileName, load().FileName)
+	})
+
+	t.Run("A name taken in another folder is allowed", func(t *testing.T) {
+		w := rename("taken.txt")
+		assert.Equal(t, http.StatusOK, w.Code)
+		file := load()
+		assert.Equal(t, user.ID.String()+"/taken.txt", file.FileName)
+		// The content stays where it was stored
+		assert.Equal(t, first.FileName, file.ObjectName)
+	})
+
+	t.Run("Paths are reduced to the base name", func(t *testing.T) {
+		w := rename("../other/renamed.txt")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, user.ID.String()+"/renamed.txt", load().FileName)
+
+		w = rename("/")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
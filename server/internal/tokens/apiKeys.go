@@ -0,0 +1,99 @@
+// internal/tokens/apiKeys.go
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// APIKeyPrefix starts every API key, which tells them apart from JWTs
+	APIKeyPrefix = "gs_"
+	// apiKeyActivityKey is a hash of API key ID to the unix time of its
+	// latest request, waiting to be written to the database
+	apiKeyActivityKey = "api_key_activity"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid, expired or revoked API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// IsAPIKey reports whether a bearer token is an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// CreateAPIKey stores a new key for the user and returns it. The key
+// itself can't be recovered later.
+func (m *Manager) CreateAPIKey(apiKey *models.APIKey) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating API key: %v", err)
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	apiKey.Prefix = key[:len(APIKeyPrefix)+8]
+	apiKey.KeyHash = hashToken(key)
+	if result := m.db.Create(apiKey); result.Error != nil {
+		return "", fmt.Errorf("error saving API key: %v", result.Error)
+	}
+	return key, nil
+}
+
+// APIKey looks up an active key and records that it was used.
+func (m *Manager) APIKey(ctx context.Context, key string) (models.APIKey, error) {
+	var apiKey models.APIKey
+	result := m.db.Where("key_hash = ? AND revoked_at IS NULL", hashToken(key)).First(&apiKey)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if result.Error != nil {
+		return models.APIKey{}, fmt.Errorf("error loading API key: %v", result.Error)
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	// The time is written to the key by the activity worker
+	err := m.cache.HSet(ctx, apiKeyActivityKey, apiKey.ID.String(), time.Now().Unix()).Err()
+	if err != nil {
+		log.Printf("Failed to record API key use: %v", err)
+	}
+	return apiKey, nil
+}
+
+// APIKeys returns the user's keys that haven't been revoked, newest first.
+func (m *Manager) APIKeys(userID uuid.UUID) ([]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	result := m.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&apiKeys)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error loading API keys: %v", result.Error)
+	}
+	return apiKeys, nil
+}
+
+// RevokeAPIKey stops one of the user's keys from working. Keys are checked
+// against the database on every request, so this takes effect at once.
+func (m *Manager) RevokeAPIKey(userID uuid.UUID, keyID uuid.UUID) error {
+	result := m.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("error revoking API key: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// internal/tokens/sessions.go
+package tokens
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// sessionActivityKey is a hash of session ID to the unix time of its
+	// latest request, waiting to be written to the database
+	sessionActivityKey    = "session_activity"
+	activityFlushInterval = time.Minute
+)
+
+// Touch records that the session just made a request. The time is kept in
+// the cache and written to the session by the activity worker.
+func (m *Manager) Touch(ctx context.Context, sessionID uuid.UUID) error {
+	return m.cache.HSet(ctx, sessionActivityKey, sessionID.String(), time.Now().Unix()).Err()
+}
+
+// Sessions returns the user's active sessions, most recently used first.
+// Activity that hasn't been written yet is included.
+func (m *Manager) Sessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	result := m.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error loading sessions: %v", result.Error)
+	}
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+
+	fields := make([]string, len(sessions))
+	for i, session := range sessions {
+		fields[i] = session.ID.String()
+	}
+	pending, err := m.cache.HMGet(ctx, sessionActivityKey, fields...).Result()
+	if err != nil {
+		log.Printf("Failed to load pending session activity: %v", err)
+		return sessions, nil
+	}
+	for i, value := range pending {
+		if seen, ok := parseUnix(value); ok && seen.After(sessions[i].LastSeenAt) {
+			sessions[i].LastSeenAt = seen
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// StartActivityWorker writes the recorded session activity and API key use
+// to the database every minute until ctx is done, and once more on the way
+// out.
+func (m *Manager) StartActivityWorker(ctx context.Context) {
+	ticker := time.NewTicker(activityFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.flushActivity(context.Background())
+			return
+		case <-ticker.C:
+		}
+
+		m.flushActivity(ctx)
+	}
+}
+
+func (m *Manager) flushActivity(ctx context.Context) {
+	m.flushHash(ctx, sessionActivityKey, func(sessionID uuid.UUID, seen time.Time) error {
+		return m.db.Model(&models.Session{}).
+			Where("id = ? AND last_seen_at < ?", sessionID, seen).
+			Update("last_seen_at", seen).Error
+	})
+	m.flushHash(ctx, apiKeyActivityKey, func(keyID uuid.UUID, used time.Time) error {
+		return m.db.Model(&models.APIKey{}).
+			Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", keyID, used).
+			Update("last_used_at", used).Error
+	})
+}
+
+// flushHash takes the pending times out of a hash in the cache and saves
+// each one with one write per ID.
+func (m *Manager) flushHash(ctx context.Context, key string, save func(id uuid.UUID, at time.Time) error) {
+	// Read and clear in one step so activity recorded meanwhile goes into
+	// the next batch
+	var pending *redis.MapStringStringCmd
+	_, err := m.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pending = pipe.HGetAll(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read %s: %v", key, err)
+		return
+	}
+
+	for rawID, value := range pending.Val() {
+		id, err := uuid.Parse(rawID)
+		if err != nil {
+			continue
+		}
+		at, ok := parseUnix(value)
+		if !ok {
+			continue
+		}
+		if err := save(id, at); err != nil {
+			log.Printf("Failed to save %s for %s: %v", key, id, err)
+		}
+	}
+}
+
+func parseUnix(value interface{}) (time.Time, bool) {
+	text, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
@@ -0,0 +1,335 @@
+// internal/tokens/tokens.go
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/config"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidToken        = errors.New("invalid or expired token")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrSessionNotFound     = errors.New("session not found")
+	// ErrRefreshTokenReused means a refresh token was presented after it
+	// had already been exchanged. The whole session is revoked, since
+	// either the client or an attacker holds a stolen copy.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// Manager issues access and refresh tokens and revokes them. Refresh
+// tokens live in the database. Revoked sessions are also listed in the
+// cache until their last access token has expired, so checking an access
+// token never touches the database.
+type Manager struct {
+	config config.AuthConfig
+	db     *gorm.DB
+	cache  *redis.Client
+}
+
+func New(cfg config.AuthConfig, db *gorm.DB, cache *redis.Client) *Manager {
+	return &Manager{config: cfg, db: db, cache: cache}
+}
+
+// Pair is what a client gets when it logs in and on every refresh.
+type Pair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// accessClaims is the payload of an access token.
+type accessClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid"`
+}
+
+// Claims identify the user and login session behind an access token.
+type Claims struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	ExpiresAt time.Time
+}
+
+// Client describes the device a session was started from.
+type Client struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Issue starts a new login session for the user.
+func (m *Manager) Issue(userID uuid.UUID, client Client) (Pair, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:     userID,
+		UserAgent:  truncate(client.UserAgent, 512),
+		IPAddress:  client.IPAddress,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(m.config.RefreshTokenTTL),
+	}
+
+	var refreshToken string
+	var row models.RefreshToken
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Create(&session); result.Error != nil {
+			return fmt.Errorf("error saving session: %v", result.Error)
+		}
+		var err error
+		refreshToken, row, err = m.newRefreshToken(userID, session.ID)
+		if err != nil {
+			return err
+		}
+		if result := tx.Create(&row); result.Error != nil {
+			return fmt.Errorf("error saving refresh token: %v", result.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		return Pair{}, err
+	}
+	return m.pair(row, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new pair and revokes it. Using a
+// token a second time revokes its whole session.
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (Pair, error) {
+	var current models.RefreshToken
+	result := m.db.Where("token_hash = ?", hashToken(refreshToken)).First(&current)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return Pair{}, ErrInvalidRefreshToken
+	}
+	if result.Error != nil {
+		return Pair{}, fmt.Errorf("error loading refresh token: %v", result.Error)
+	}
+
+	if current.RevokedAt != nil {
+		if current.ReplacedByID == nil {
+			// Revoked by a logout, not by a refresh
+			return Pair{}, ErrInvalidRefreshToken
+		}
+		return Pair{}, m.reused(ctx, current)
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return Pair{}, ErrInvalidRefreshToken
+	}
+
+	newToken, next, err := m.newRefreshToken(current.UserID, current.SessionID)
+	if err != nil {
+		return Pair{}, err
+	}
+	err = m.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Create(&next); result.Error != nil {
+			return fmt.Errorf("error saving refresh token: %v", result.Error)
+		}
+		// Only one of two concurrent refreshes with the same token gets to
+		// revoke it, the other one counts as reuse
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by_id": next.ID})
+		if result.Error != nil {
+			return fmt.Errorf("error revoking refresh token: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+		// Refreshing keeps the session alive and counts as activity
+		result = tx.Model(&models.Session{}).Where("id = ?", current.SessionID).
+			Updates(map[string]interface{}{"expires_at": next.ExpiresAt, "last_seen_at": time.Now()})
+		if result.Error != nil {
+			return fmt.Errorf("error updating session: %v", result.Error)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		return Pair{}, m.reused(ctx, current)
+	}
+	if err != nil {
+		return Pair{}, err
+	}
+	return m.pair(next, newToken)
+}
+
+func (m *Manager) reused(ctx context.Context, token models.RefreshToken) error {
+	log.Printf("Refresh token reuse detected for user %s, revoking session %s", token.UserID, token.SessionID)
+	err := m.RevokeSession(ctx, token.UserID, token.SessionID)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// Parse checks an access token's signature, expiry and revocation.
+func (m *Manager) Parse(ctx context.Context, accessToken string) (Claims, error) {
+	var claims accessClaims
+	token, err := jwt.ParseWithClaims(accessToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(m.config.JWTSecret), nil
+	}, jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return Claims{}, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	// Tokens from before sessions existed can't be revoked, so they are
+	// not accepted either
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	revoked, err := m.cache.Exists(ctx, revokedSessionKey(sessionID)).Result()
+	if err != nil {
+		return Claims{}, fmt.Errorf("error checking token revocation: %v", err)
+	}
+	if revoked > 0 {
+		return Claims{}, ErrTokenRevoked
+	}
+
+	return Claims{UserID: userID, SessionID: sessionID, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// RevokeSession logs out one of the user's sessions: its refresh tokens
+// stop working right away and so do its access tokens. It returns
+// ErrSessionNotFound if the user has no such active session.
+func (m *Manager) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	found := false
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("error revoking session: %v", result.Error)
+		}
+		found = result.RowsAffected > 0
+
+		result = tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND session_id = ? AND revoked_at IS NULL", userID, sessionID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("error revoking refresh tokens: %v", result.Error)
+		}
+		found = found || result.RowsAffected > 0
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	return m.denySessions(ctx, sessionID)
+}
+
+// RevokeAll logs out every session of the user.
+func (m *Manager) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	var sessionIDs []uuid.UUID
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Distinct().Pluck("session_id", &sessionIDs)
+		if result.Error != nil {
+			return fmt.Errorf("error loading sessions: %v", result.Error)
+		}
+		result = tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("error revoking sessions: %v", result.Error)
+		}
+		result = tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("error revoking refresh tokens: %v", result.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return m.denySessions(ctx, sessionIDs...)
+}
+
+// denySessions lists sessions as revoked for as long as one of their
+// access tokens may still be valid.
+func (m *Manager) denySessions(ctx context.Context, sessionIDs ...uuid.UUID) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	pipe := m.cache.Pipeline()
+	for _, sessionID := range sessionIDs {
+		pipe.Set(ctx, revokedSessionKey(sessionID), 1, m.config.AccessTokenTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error saving revoked sessions: %v", err)
+	}
+	return nil
+}
+
+func (m *Manager) newRefreshToken(userID uuid.UUID, sessionID uuid.UUID) (string, models.RefreshToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", models.RefreshToken{}, fmt.Errorf("error generating refresh token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, models.RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(m.config.RefreshTokenTTL),
+	}, nil
+}
+
+func (m *Manager) pair(row models.RefreshToken, refreshToken string) (Pair, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   row.UserID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.config.AccessTokenTTL)),
+		},
+		SessionID: row.SessionID.String(),
+	})
+	accessToken, err := token.SignedString([]byte(m.config.JWTSecret))
+	if err != nil {
+		return Pair{}, fmt.Errorf("error signing token: %v", err)
+	}
+	return Pair{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresIn: m.config.AccessTokenTTL}, nil
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func revokedSessionKey(sessionID uuid.UUID) string {
+	return "revoked_session:" + sessionID.String()
+}
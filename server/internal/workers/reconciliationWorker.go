@@ -0,0 +1,310 @@
+// internal/workers/reconciliationWorker.go
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/blobs"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"gorm.io/gorm"
+)
+
+const (
+	reconcileLockKey = "reconcile_lock"
+	reconcileLockTTL = time.Hour
+)
+
+const (
+	ReconcileKindFile    = "file"
+	ReconcileKindVersion = "version"
+	ReconcileKindBlob    = "blob"
+)
+
+// ErrReconcileRunning is returned when another reconciliation holds the lock.
+var ErrReconcileRunning = errors.New("reconciliation already running")
+
+// ReconcileReport lists where the bucket and the database disagree. With
+// Repair set, every entry has been fixed unless its Error says otherwise.
+type ReconcileReport struct {
+	Repair         bool            `json:"repair"`
+	StartedAt      time.Time       `json:"started_at"`
+	FinishedAt     time.Time       `json:"finished_at"`
+	ObjectsScanned int             `json:"objects_scanned"`
+	RowsScanned    int             `json:"rows_scanned"`
+	OrphanObjects  []OrphanObject  `json:"orphan_objects"`
+	MissingObjects []MissingObject `json:"missing_objects"`
+	SizeMismatches []SizeMismatch  `json:"size_mismatches"`
+}
+
+// OrphanObject is an object no file, version or blob refers to. Repair
+// removes it.
+type OrphanObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// MissingObject is a row whose object is gone. Repair deletes the row, and
+// for a file everything that belongs to it.
+type MissingObject struct {
+	Kind  string `json:"kind"`
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+	Error string `json:"error,omitempty"`
+}
+
+// SizeMismatch is a row whose recorded size differs from its object. Repair
+// trusts the object and updates the row.
+type SizeMismatch struct {
+	Kind         string `json:"kind"`
+	ID           string `json:"id"`
+	Key          string `json:"key"`
+	RecordedSize int64  `json:"recorded_size"`
+	ObjectSize   int64  `json:"object_size"`
+	Error        string `json:"error,omitempty"`
+}
+
+func (w *Workers) StartReconciliationWorker(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(w.config.Reconcile.IntervalHours) * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		repair := w.config.Reconcile.AutoRepair
+		report, err := w.Reconcile(ctx, repair)
+		if err != nil {
+			log.Printf("Failed to reconcile storage: %v", err)
+			continue
+		}
+		log.Printf("Reconciled storage (repair=%t): %d orphan objects, %d missing objects, %d size mismatches",
+			repair, len(report.OrphanObjects), len(report.MissingObjects), len(report.SizeMismatches))
+	}
+}
+
+// reconcileRow is a row that expects an object of a given size.
+type reconcileRow struct {
+	kind string
+	id   string
+	key  string
+	size int64
+}
+
+// Reconcile walks the bucket and the file, version and blob tables and
+// reports orphan objects, rows without objects and size mismatches. With
+// repair set it also fixes them.
+func (w *Workers) Reconcile(ctx context.Context, repair bool) (ReconcileReport, error) {
+	locked, err := w.cache.SetNX(ctx, reconcileLockKey, 1, reconcileLockTTL).Result()
+	if err != nil {
+		return ReconcileReport{}, fmt.Errorf("error locking reconciliation: %v", err)
+	}
+	if !locked {
+		return ReconcileReport{}, ErrReconcileRunning
+	}
+	defer w.cache.Del(context.Background(), reconcileLockKey)
+
+	report := ReconcileReport{
+		Repair:         repair,
+		StartedAt:      time.Now(),
+		OrphanObjects:  []OrphanObject{},
+		MissingObjects: []MissingObject{},
+		SizeMismatches: []SizeMismatch{},
+	}
+
+	// Rows are loaded before the bucket is listed. An upload that lands in
+	// between then only adds an object, which is too young to be reported
+	// as an orphan, instead of a row that seems to have no object.
+	rows, referencedPrefixes, err := w.loadReconcileRows()
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+	report.RowsScanned = len(rows)
+
+	objects := make(map[string]storage.ObjectInfo)
+	err = w.storage.List(ctx, "", func(object storage.ObjectInfo) error {
+		objects[object.Key] = object
+		return nil
+	})
+	if err != nil {
+		return ReconcileReport{}, fmt.Errorf("error listing objects: %v", err)
+	}
+	report.ObjectsScanned = len(objects)
+
+	referenced := make(map[string]bool)
+	for _, row := range rows {
+		referenced[row.key] = true
+
+		object, exists := objects[row.key]
+		if !exists {
+			// Look again before reporting it, in case the object was
+			// written after the listing
+			object, err = w.storage.Stat(ctx, row.key)
+			exists = err == nil
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return ReconcileReport{}, fmt.Errorf("error checking object %s: %v", row.key, err)
+			}
+		}
+		if !exists {
+			missing := MissingObject{Kind: row.kind, ID: row.id, Key: row.key}
+			if repair {
+				if err := w.removeGhostRow(row); err != nil {
+					missing.Error = err.Error()
+				}
+			}
+			report.MissingObjects = append(report.MissingObjects, missing)
+			continue
+		}
+
+		if object.Size != row.size {
+			mismatch := SizeMismatch{Kind: row.kind, ID: row.id, Key: row.key, RecordedSize: row.size, ObjectSize: object.Size}
+			if repair {
+				if err := w.repairSize(ctx, row, object.Size); err != nil {
+					mismatch.Error = err.Error()
+				}
+			}
+			report.SizeMismatches = append(report.SizeMismatches, mismatch)
+		}
+	}
+
+	cutoff := time.Now().Add(-w.reconcileGracePeriod())
+	for key, object := range objects {
+		if referenced[key] || object.LastModified.After(cutoff) || hasAnyPrefix(key, referencedPrefixes) {
+			continue
+		}
+		orphan := OrphanObject{Key: key, Size: object.Size, LastModified: object.LastModified}
+		if repair {
+			if err := w.storage.Delete(ctx, key); err != nil {
+				orphan.Error = err.Error()
+			}
+		}
+		report.OrphanObjects = append(report.OrphanObjects, orphan)
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// loadReconcileRows collects every row that expects an object, including
+// trashed files. Files and versions are listed before blobs so a repair
+// drops their references before it looks at the blob itself. Active tus
+// uploads keep their tail objects under a prefix instead.
+func (w *Workers) loadReconcileRows() ([]reconcileRow, []string, error) {
+	var rows []reconcileRow
+
+	var files []models.FileMetadata
+	if result := w.db.Unscoped().Find(&files); result.Error != nil {
+		return nil, nil, fmt.Errorf("error finding files: %v", result.Error)
+	}
+	for _, file := range files {
+		rows = append(rows, reconcileRow{kind: ReconcileKindFile, id: file.ID.String(), key: file.StorageKey(), size: file.FileSize})
+	}
+
+	var versions []models.FileVersion
+	if result := w.db.Find(&versions); result.Error != nil {
+		return nil, nil, fmt.Errorf("error finding versions: %v", result.Error)
+	}
+	for _, version := range versions {
+		rows = append(rows, reconcileRow{kind: ReconcileKindVersion, id: version.ID.String(), key: version.ObjectName, size: version.FileSize})
+	}
+
+	var blobRows []models.Blob
+	if result := w.db.Find(&blobRows); result.Error != nil {
+		return nil, nil, fmt.Errorf("error finding blobs: %v", result.Error)
+	}
+	for _, blob := range blobRows {
+		rows = append(rows, reconcileRow{kind: ReconcileKindBlob, id: blob.Hash, key: blob.ObjectName, size: blob.Size})
+	}
+
+	var uploads []models.TusUpload
+	if result := w.db.Where("status = ?", models.UploadSessionActive).Find(&uploads); result.Error != nil {
+		return nil, nil, fmt.Errorf("error finding tus uploads: %v", result.Error)
+	}
+	prefixes := make([]string, 0, len(uploads))
+	for _, upload := range uploads {
+		prefixes = append(prefixes, ".tus/"+upload.ID.String()+"/")
+	}
+
+	return rows, prefixes, nil
+}
+
+// removeGhostRow deletes a row whose object is gone.
+func (w *Workers) removeGhostRow(row reconcileRow) error {
+	switch row.kind {
+	case ReconcileKindFile:
+		var file models.FileMetadata
+		if result := w.db.Unscoped().Where("id = ?", row.id).First(&file); result.Error != nil {
+			return result.Error
+		}
+		err := blobs.PurgeFile(w.db, &file)
+		w.outbox.Notify()
+		return err
+
+	case ReconcileKindVersion:
+		var version models.FileVersion
+		result := w.db.Where("id = ?", row.id).Limit(1).Find(&version)
+		if result.Error != nil || result.RowsAffected == 0 {
+			// Already gone with its file
+			return result.Error
+		}
+		err := w.db.Transaction(func(tx *gorm.DB) error {
+			if err := blobs.DropTx(tx, version.ObjectName, version.ContentHash); err != nil {
+				return err
+			}
+			return tx.Delete(&version).Error
+		})
+		w.outbox.Notify()
+		return err
+
+	default:
+		// Dropping the files and versions above may have deleted it already
+		return w.db.Where("hash = ?", row.id).Delete(&models.Blob{}).Error
+	}
+}
+
+// repairSize records the object's size on the row.
+func (w *Workers) repairSize(ctx context.Context, row reconcileRow, size int64) error {
+	switch row.kind {
+	case ReconcileKindFile:
+		result := w.db.Unscoped().Model(&models.FileMetadata{}).Where("id = ?", row.id).Update("file_size", size)
+		if result.Error != nil {
+			return result.Error
+		}
+		var file models.FileMetadata
+		if w.db.Unscoped().Where("id = ?", row.id).First(&file).Error == nil {
+			w.cache.Del(ctx, "files:"+file.UserID.String())
+			w.invalidateCache(ctx, file.UserID)
+		}
+		return nil
+	case ReconcileKindVersion:
+		return w.db.Model(&models.FileVersion{}).Where("id = ?", row.id).Update("file_size", size).Error
+	default:
+		return w.db.Model(&models.Blob{}).Where("hash = ?", row.id).Update("size", size).Error
+	}
+}
+
+// reconcileGracePeriod returns how old an object has to be before it can
+// be reported as an orphan. Younger ones may belong to an upload whose
+// metadata isn't saved yet.
+func (w *Workers) reconcileGracePeriod() time.Duration {
+	return time.Duration(w.config.Reconcile.GraceHours) * time.Hour
+}
+
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
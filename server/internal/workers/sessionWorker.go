@@ -0,0 +1,49 @@
+// internal/workers/sessionWorker.go
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+)
+
+func (w *Workers) StartSessionWorker(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.deleteExpiredSessions()
+	}
+}
+
+// deleteExpiredSessions removes sessions and refresh tokens that can no
+// longer be used. Revoked ones are kept until then so that reusing their
+// tokens is still detected.
+func (w *Workers) deleteExpiredSessions() {
+	now := time.Now()
+	result := w.db.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+	if result.Error != nil {
+		log.Printf("Failed to delete expired refresh tokens: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Deleted %d expired refresh tokens", result.RowsAffected)
+	}
+
+	result = w.db.Where("expires_at < ?", now).Delete(&models.Session{})
+	if result.Error != nil {
+		log.Printf("Failed to delete expired sessions: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Deleted %d expired sessions", result.RowsAffected)
+	}
+}
@@ -0,0 +1,47 @@
+// internal/workers/trashPurgeWorker.go
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/blobs"
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+)
+
+func (w *Workers) StartTrashPurgeWorker(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.purgeTrashedFiles()
+	}
+}
+
+// purgeTrashedFiles permanently deletes files that have been in the trash
+// for longer than the trash retention.
+func (w *Workers) purgeTrashedFiles() {
+	var trashedFiles []models.FileMetadata
+
+	cutoff := time.Now().Add(-time.Duration(w.config.Limits.TrashRetentionDays) * 24 * time.Hour)
+
+	result := w.db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Find(&trashedFiles)
+	if result.Error != nil {
+		log.Printf("Failed to find trashed files: %v", result.Error)
+		return
+	}
+
+	for _, file := range trashedFiles {
+		if err := blobs.PurgeFile(w.db, &file); err != nil {
+			log.Printf("Failed to purge trashed file %s: %v", file.ID, err)
+		}
+	}
+	w.outbox.Notify()
+}
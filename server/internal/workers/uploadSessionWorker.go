@@ -0,0 +1,92 @@
+// internal/workers/uploadSessionWorker.go
+package workers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/models"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+)
+
+func (w *Workers) StartUploadSessionWorker(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.abortExpiredUploadSessions()
+		w.abortExpiredTusUploads()
+	}
+}
+
+// abortExpiredUploadSessions releases the S3 parts of sessions that were
+// never completed so they stop counting against the bucket.
+func (w *Workers) abortExpiredUploadSessions() {
+	ctx := context.Background()
+	var sessions []models.UploadSession
+
+	result := w.db.Where("status = ? AND expires_at < ?", models.UploadSessionActive, time.Now()).Find(&sessions)
+	if result.Error != nil {
+		log.Printf("Failed to find expired upload sessions: %v", result.Error)
+		return
+	}
+
+	for _, session := range sessions {
+		err := w.storage.AbortMultipartUpload(ctx, session.StorageKey(), session.S3UploadID)
+		if err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+			log.Printf("Failed to abort multipart upload: %v", err)
+			continue
+		}
+
+		session.Status = models.UploadSessionAborted
+		result := w.db.Save(&session)
+		if result.Error != nil {
+			log.Printf("Failed to update upload session: %v", result.Error)
+		}
+	}
+}
+
+// abortExpiredTusUploads does the same for tus uploads, which also leave a
+// tail object behind.
+func (w *Workers) abortExpiredTusUploads() {
+	ctx := context.Background()
+	var uploads []models.TusUpload
+
+	result := w.db.Where("status = ? AND expires_at < ?", models.UploadSessionActive, time.Now()).Find(&uploads)
+	if result.Error != nil {
+		log.Printf("Failed to find expired tus uploads: %v", result.Error)
+		return
+	}
+
+	for _, upload := range uploads {
+		err := w.storage.AbortMultipartUpload(ctx, upload.StorageKey(), upload.S3UploadID)
+		if err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+			log.Printf("Failed to abort multipart upload: %v", err)
+			continue
+		}
+
+		err = w.storage.List(ctx, ".tus/"+upload.ID.String()+"/", func(object storage.ObjectInfo) error {
+			if err := w.storage.Delete(ctx, object.Key); err != nil {
+				log.Printf("Failed to delete tus temporary object: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to list tus temporary objects: %v", err)
+		}
+
+		upload.Status = models.UploadSessionAborted
+		result := w.db.Save(&upload)
+		if result.Error != nil {
+			log.Printf("Failed to update tus upload: %v", result.Error)
+		}
+	}
+}
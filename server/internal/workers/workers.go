@@ -0,0 +1,34 @@
+// internal/workers/workers.go
+package workers
+
+import (
+	"context"
+
+	"github.com/ayushh2k/go-store-s3/server/internal/config"
+	"github.com/ayushh2k/go-store-s3/server/internal/outbox"
+	"github.com/ayushh2k/go-store-s3/server/internal/storage"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Workers runs the background jobs against one database, cache and bucket.
+type Workers struct {
+	config  config.Config
+	db      *gorm.DB
+	cache   *redis.Client
+	storage storage.Backend
+	outbox  *outbox.Outbox
+}
+
+func New(cfg config.Config, db *gorm.DB, cache *redis.Client, store storage.Backend, box *outbox.Outbox) *Workers {
+	return &Workers{config: cfg, db: db, cache: cache, storage: store, outbox: box}
+}
+
+// Start runs every worker until ctx is done.
+func (w *Workers) Start(ctx context.Context) {
+	go w.StartFileDeletionWorker(ctx)
+	go w.StartUploadSessionWorker(ctx)
+	go w.StartTrashPurgeWorker(ctx)
+	go w.StartReconciliationWorker(ctx)
+	go w.StartSessionWorker(ctx)
+}